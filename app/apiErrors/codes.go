@@ -0,0 +1,56 @@
+package apiErrors
+
+import "net/http"
+
+// Error codes returned to clients in APIError.Code.
+// The packages creating errors pick a code and statuses decides how it is rendered over HTTP,
+// so no package other than this one deals with HTTP statuses.
+const (
+	NotFoundCode            ErrorCode = "NOT_FOUND"
+	BadRequestCode          ErrorCode = "BAD_REQUEST"
+	InternalServerErrorCode ErrorCode = "INTERNAL_SERVER_ERROR"
+
+	DatabaseErrorCode            ErrorCode = "database_error"
+	DatabaseNotFoundCode         ErrorCode = "not_found"
+	ConstraintViolationErrorCode ErrorCode = "constraint_violation"
+	UniqueViolationErrorCode     ErrorCode = "unique_violation"
+	ForeignKeyViolationErrorCode ErrorCode = "foreign_key_violation"
+	NotNullViolationErrorCode    ErrorCode = "not_null_violation"
+	SerializationErrorCode       ErrorCode = "serialization_failure"
+	QueryCanceledErrorCode       ErrorCode = "query_canceled"
+	TimeoutErrorCode             ErrorCode = "timeout"
+	ConnectionErrorCode          ErrorCode = "connection_error"
+	InvalidCursorErrorCode       ErrorCode = "invalid_cursor"
+)
+
+var statuses = map[ErrorCode]int{
+	NotFoundCode:            http.StatusNotFound,
+	BadRequestCode:          http.StatusBadRequest,
+	InternalServerErrorCode: http.StatusInternalServerError,
+
+	DatabaseErrorCode:            http.StatusInternalServerError,
+	DatabaseNotFoundCode:         http.StatusNotFound,
+	ConstraintViolationErrorCode: http.StatusBadRequest,
+	UniqueViolationErrorCode:     http.StatusConflict,
+	ForeignKeyViolationErrorCode: http.StatusConflict,
+	NotNullViolationErrorCode:    http.StatusBadRequest,
+	// The request may succeed when sent again
+	SerializationErrorCode: http.StatusConflict,
+	QueryCanceledErrorCode: http.StatusServiceUnavailable,
+	TimeoutErrorCode:       http.StatusGatewayTimeout,
+	ConnectionErrorCode:    http.StatusServiceUnavailable,
+	InvalidCursorErrorCode: http.StatusBadRequest,
+}
+
+// StatusOf returns the HTTP status of the code. Unknown codes are internal server errors.
+func StatusOf(code ErrorCode) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// NewWithCode creates an APIError rendered with the status of its code
+func NewWithCode(code ErrorCode, message string) *APIError {
+	return New(string(code), message, StatusOf(code))
+}
@@ -28,8 +28,8 @@ func NewNotFoundError(message string) *APIError {
 		errorMessage = "Resource not found"
 	}
 	return &APIError{
-		Code:    "NOT_FOUND",
-		Status:  404,
+		Code:    string(NotFoundCode),
+		Status:  StatusOf(NotFoundCode),
 		Message: errorMessage,
 	}
 }
@@ -40,8 +40,20 @@ func NewGenericError(message string) *APIError {
 		errorMessage = "Something went wrong"
 	}
 	return &APIError{
-		Code:    "INTERNAL_SERVER_ERROR",
-		Status:  500,
+		Code:    string(InternalServerErrorCode),
+		Status:  StatusOf(InternalServerErrorCode),
+		Message: errorMessage,
+	}
+}
+
+func NewBadRequestError(message string) *APIError {
+	errorMessage := message
+	if errorMessage == "" {
+		errorMessage = "Bad request"
+	}
+	return &APIError{
+		Code:    string(BadRequestCode),
+		Status:  StatusOf(BadRequestCode),
 		Message: errorMessage,
 	}
 }
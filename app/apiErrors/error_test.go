@@ -149,3 +149,30 @@ func TestNewGenericError(t *testing.T) {
 	})
 
 }
+
+func TestNewBadRequestError(t *testing.T) {
+
+	t.Run("Test with custom message", func(t *testing.T) {
+		customErr := NewBadRequestError("title is required")
+		assert.Equal(t, "BAD_REQUEST", customErr.Code, "Expected code BAD_REQUEST")
+		assert.Equal(t, 400, customErr.Status, "Expected status 400")
+		assert.Equal(t, "title is required", customErr.Message, "Expected message 'title is required'")
+	})
+
+	t.Run("Test with empty message (default message)", func(t *testing.T) {
+		defaultErr := NewBadRequestError("")
+		assert.Equal(t, "BAD_REQUEST", defaultErr.Code, "Expected code BAD_REQUEST")
+		assert.Equal(t, 400, defaultErr.Status, "Expected status 400")
+		assert.Equal(t, "Bad request", defaultErr.Message, "Expected message 'Bad request'")
+	})
+}
+
+func TestNewWithCode(t *testing.T) {
+	err := NewWithCode(UniqueViolationErrorCode, "resource already exists")
+	assert.Equal(t, "unique_violation", err.Code)
+	assert.Equal(t, 409, err.Status)
+	assert.Equal(t, "resource already exists", err.Message)
+
+	assert.Equal(t, 504, StatusOf(TimeoutErrorCode))
+	assert.Equal(t, 500, StatusOf("unknown"), "Unknown codes should be internal server errors")
+}
@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"example/web-service-gin/app/apiErrors"
+	"example/web-service-gin/app/appTracer"
+	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/config"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultBreakerFailures      = 5
+	defaultBreakerProbeInterval = time.Second
+	breakerProbeKey             = "breaker:probe"
+	breakerServiceName          = "cacheBreaker"
+)
+
+// ErrCacheUnavailable is returned without calling the cache while the breaker is open
+var ErrCacheUnavailable = apiErrors.NewGenericError("cache unavailable")
+
+type BreakerState string
+
+const (
+	// BreakerClosed lets every call through to the cache
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects every call until a background probe succeeds
+	BreakerOpen BreakerState = "open"
+)
+
+// BreakerStatus is a snapshot of the breaker, suitable for health checks
+type BreakerStatus struct {
+	State BreakerState `json:"state"`
+	// Failures is the number of consecutive failures seen while closed
+	Failures int `json:"failures"`
+	// OpenedAt is when the breaker last opened, zero if it never did
+	OpenedAt  time.Time `json:"openedAt,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// StatusReporter reports the state of the breaker in front of a cache, for health checks.
+// The breaker cacher implements it, and so does the tiered cacher for the breaker of its L2.
+type StatusReporter interface {
+	Status() BreakerStatus
+}
+
+// BreakerCacher is a Cacher that stops calling the cache it wraps while that cache is failing
+type BreakerCacher interface {
+	Cacher
+	StatusReporter
+}
+
+// breakerCache opens after a number of consecutive failures of the wrapped cache and rejects
+// calls with ErrCacheUnavailable from then on, so an unhealthy cache adds no latency to requests.
+// While open a background probe checks the cache and closes the breaker once it answers again.
+// Misses are answers, so only errors other than ErrCacheMiss count as failures.
+type breakerCache struct {
+	next          Cacher
+	appTracer     appTracer.AppTracer
+	failures      int
+	probeInterval time.Duration
+
+	mu     sync.Mutex
+	status BreakerStatus
+}
+
+func NewBreakerCacher(next Cacher, cfg config.BreakerConfig, appTracer appTracer.AppTracer) BreakerCacher {
+	failures := cfg.Failures
+	if failures <= 0 {
+		failures = defaultBreakerFailures
+	}
+	probeInterval := cfg.ProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = defaultBreakerProbeInterval
+	}
+	return &breakerCache{
+		next:          next,
+		appTracer:     appTracer,
+		failures:      failures,
+		probeInterval: probeInterval,
+		status:        BreakerStatus{State: BreakerClosed},
+	}
+}
+
+func (bc *breakerCache) Status() BreakerStatus {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.status
+}
+
+func (bc *breakerCache) Get(serviceName string, ctx context.Context, key string) (string, error) {
+	if err := bc.allow(serviceName, ctx, "get", key); err != nil {
+		return "", err
+	}
+	val, err := bc.next.Get(serviceName, ctx, key)
+	bc.report(ctx, err)
+	return val, err
+}
+
+func (bc *breakerCache) Set(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) error {
+	if err := bc.allow(serviceName, ctx, "set", key); err != nil {
+		return err
+	}
+	err := bc.next.Set(serviceName, ctx, key, value, expiration)
+	bc.report(ctx, err)
+	return err
+}
+
+func (bc *breakerCache) SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	if err := bc.allow(serviceName, ctx, "setnx", key); err != nil {
+		return false, err
+	}
+	set, err := bc.next.SetNX(serviceName, ctx, key, value, expiration)
+	bc.report(ctx, err)
+	return set, err
+}
+
+func (bc *breakerCache) Delete(serviceName string, ctx context.Context, keys ...string) error {
+	if err := bc.allow(serviceName, ctx, "delete", keys...); err != nil {
+		return err
+	}
+	err := bc.next.Delete(serviceName, ctx, keys...)
+	bc.report(ctx, err)
+	return err
+}
+
+func (bc *breakerCache) CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error) {
+	if err := bc.allow(serviceName, ctx, "compareanddelete", key); err != nil {
+		return false, err
+	}
+	deleted, err := bc.next.CompareAndDelete(serviceName, ctx, key, value)
+	bc.report(ctx, err)
+	return deleted, err
+}
+
+func (bc *breakerCache) SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error {
+	if err := bc.allow(serviceName, ctx, "set", key); err != nil {
+		return err
+	}
+	err := bc.next.SetWithTags(serviceName, ctx, key, value, expiration, tags...)
+	bc.report(ctx, err)
+	return err
+}
+
+func (bc *breakerCache) InvalidateTags(serviceName string, ctx context.Context, tags ...string) error {
+	if err := bc.allow(serviceName, ctx, "invalidate", tags...); err != nil {
+		return err
+	}
+	err := bc.next.InvalidateTags(serviceName, ctx, tags...)
+	bc.report(ctx, err)
+	return err
+}
+
+func (bc *breakerCache) MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error) {
+	if err := bc.allow(serviceName, ctx, "mget", keys...); err != nil {
+		return nil, err
+	}
+	values, err := bc.next.MGet(serviceName, ctx, keys...)
+	bc.report(ctx, err)
+	return values, err
+}
+
+func (bc *breakerCache) MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error {
+	if err := bc.allow(serviceName, ctx, "mset"); err != nil {
+		return err
+	}
+	err := bc.next.MSet(serviceName, ctx, values, expiration)
+	bc.report(ctx, err)
+	return err
+}
+
+func (bc *breakerCache) TTL(serviceName string, ctx context.Context, key string) (time.Duration, error) {
+	if err := bc.allow(serviceName, ctx, "ttl", key); err != nil {
+		return 0, err
+	}
+	ttl, err := bc.next.TTL(serviceName, ctx, key)
+	bc.report(ctx, err)
+	return ttl, err
+}
+
+func (bc *breakerCache) Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error {
+	if err := bc.allow(serviceName, ctx, "expire", key); err != nil {
+		return err
+	}
+	err := bc.next.Expire(serviceName, ctx, key, expiration)
+	bc.report(ctx, err)
+	return err
+}
+
+func (bc *breakerCache) Exists(serviceName string, ctx context.Context, key string) (bool, error) {
+	if err := bc.allow(serviceName, ctx, "exists", key); err != nil {
+		return false, err
+	}
+	found, err := bc.next.Exists(serviceName, ctx, key)
+	bc.report(ctx, err)
+	return found, err
+}
+
+func (bc *breakerCache) Incr(serviceName string, ctx context.Context, key string) (int64, error) {
+	if err := bc.allow(serviceName, ctx, "incr", key); err != nil {
+		return 0, err
+	}
+	value, err := bc.next.Incr(serviceName, ctx, key)
+	bc.report(ctx, err)
+	return value, err
+}
+
+func (bc *breakerCache) Decr(serviceName string, ctx context.Context, key string) (int64, error) {
+	if err := bc.allow(serviceName, ctx, "decr", key); err != nil {
+		return 0, err
+	}
+	value, err := bc.next.Decr(serviceName, ctx, key)
+	bc.report(ctx, err)
+	return value, err
+}
+
+// allow returns ErrCacheUnavailable while the breaker is open, recording the rejected call
+// like any other cache call so it shows up in the request logs and traces
+func (bc *breakerCache) allow(serviceName string, ctx context.Context, action string, keys ...string) error {
+	status := bc.Status()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.breaker", string(status.State)))
+	if status.State != BreakerOpen {
+		return nil
+	}
+
+	startTime := time.Now()
+	ctx, span := bc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	key := ""
+	if len(keys) > 0 {
+		key = keys[0]
+	}
+	recordCacheCall(ctx, span, "breaker", serviceName, action, key, startTime, ErrCacheUnavailable, false)
+	span.SetAttributes(attribute.String("cache.breaker", string(status.State)))
+	span.SetAttributes(attribute.Int64("cache.breaker.openSeconds", int64(time.Since(status.OpenedAt).Seconds())))
+	return ErrCacheUnavailable
+}
+
+// report counts consecutive failures and opens the breaker when they reach the threshold.
+// A call failing because the caller's context ended says nothing about the cache, so it isn't counted.
+func (bc *breakerCache) report(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	failed := err != nil && !errors.Is(err, ErrCacheMiss)
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.status.State == BreakerOpen {
+		return
+	}
+	if !failed {
+		bc.status.Failures = 0
+		return
+	}
+	bc.status.Failures++
+	bc.status.LastError = err.Error()
+	if bc.status.Failures >= bc.failures {
+		bc.status.State = BreakerOpen
+		bc.status.OpenedAt = time.Now()
+		trace.SpanFromContext(ctx).AddEvent("cache breaker opened")
+		go bc.probe()
+	}
+}
+
+// probe checks the wrapped cache every probeInterval while the breaker is open and closes it
+// as soon as the cache answers. The probe runs outside of any request, with a ClientContext of its own.
+func (bc *breakerCache) probe() {
+	ticker := time.NewTicker(bc.probeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.WithValue(context.Background(), clientContext.ClientContextKey, &clientContext.ClientContext{})
+		ctx, cancel := context.WithTimeout(ctx, bc.probeInterval)
+		_, err := bc.next.Exists(breakerServiceName, ctx, breakerProbeKey)
+		cancel()
+		if err != nil {
+			bc.mu.Lock()
+			bc.status.LastError = err.Error()
+			bc.mu.Unlock()
+			continue
+		}
+
+		bc.mu.Lock()
+		bc.status.State = BreakerClosed
+		bc.status.Failures = 0
+		bc.status.LastError = ""
+		bc.mu.Unlock()
+		return
+	}
+}
+
+// breakerBus publishes through the breaker of the cache it shares its redis client with, so a
+// publish to an unhealthy redis fails fast like the cache calls, and its failures open the breaker.
+// The subscription is a single long lived connection go-redis reconnects on its own, so it doesn't
+// go through the breaker.
+type breakerBus struct {
+	InvalidationBus
+	breaker *breakerCache
+}
+
+func (bb *breakerBus) Publish(serviceName string, ctx context.Context, invalidation Invalidation) error {
+	if err := bb.breaker.allow(serviceName, ctx, "publish", invalidation.Namespace); err != nil {
+		return err
+	}
+	err := bb.InvalidationBus.Publish(serviceName, ctx, invalidation)
+	bb.breaker.report(ctx, err)
+	return err
+}
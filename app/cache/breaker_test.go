@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"example/web-service-gin/config"
+	"example/web-service-gin/testUtils"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCacherTimeouts(t *testing.T) {
+	cacher := NewCacher(config.RedisClientConfig{
+		Host:         "localhost",
+		Port:         6379,
+		DialTimeout:  time.Second,
+		ReadTimeout:  200 * time.Millisecond,
+		WriteTimeout: 300 * time.Millisecond,
+	}, testUtils.NewAppTracer()).(*redisCache)
+
+	options := cacher.Client.Options()
+	assert.Equal(t, time.Second, options.DialTimeout)
+	assert.Equal(t, 200*time.Millisecond, options.ReadTimeout)
+	assert.Equal(t, 300*time.Millisecond, options.WriteTimeout)
+}
+
+func TestBreakerCache(t *testing.T) {
+	mr := miniredis.NewMiniRedis()
+	assert.NoError(t, mr.Start())
+	defer mr.Close()
+	port, _ := strconv.Atoi(mr.Port())
+
+	redisCacher := NewCacher(config.RedisClientConfig{Host: mr.Host(), Port: port, DialTimeout: 50 * time.Millisecond}, testUtils.NewAppTracer())
+	cacher := NewBreakerCacher(redisCacher, config.BreakerConfig{Failures: 2, ProbeInterval: 20 * time.Millisecond}, testUtils.NewAppTracer())
+	ctx := testUtils.CreateTestContext()
+
+	t.Run("Misses don't count as failures", func(t *testing.T) {
+		for range 3 {
+			_, err := cacher.Get(serviceName, ctx, "missing")
+			assert.Equal(t, ErrCacheMiss, err)
+		}
+		assert.Equal(t, BreakerStatus{State: BreakerClosed}, cacher.Status())
+	})
+
+	t.Run("Calls the caller canceled don't count as failures", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		for range 3 {
+			_, err := cacher.Get(serviceName, canceled, "key")
+			assert.Error(t, err)
+		}
+		assert.Equal(t, BreakerStatus{State: BreakerClosed}, cacher.Status())
+	})
+
+	t.Run("Opens after consecutive failures and skips the cache", func(t *testing.T) {
+		mr.Close()
+
+		_, err := cacher.Get(serviceName, ctx, "key")
+		assert.Equal(t, ErrCacheGeneric, err)
+		assert.Equal(t, BreakerClosed, cacher.Status().State)
+
+		_, err = cacher.Get(serviceName, ctx, "key")
+		assert.Equal(t, ErrCacheGeneric, err)
+		status := cacher.Status()
+		assert.Equal(t, BreakerOpen, status.State)
+		assert.NotEmpty(t, status.LastError)
+		assert.False(t, status.OpenedAt.IsZero())
+
+		startTime := time.Now()
+		_, err = cacher.Get(serviceName, ctx, "key")
+		assert.Equal(t, ErrCacheUnavailable, err)
+		assert.Less(t, time.Since(startTime), 10*time.Millisecond, "An open breaker should not wait for the cache")
+		assert.Equal(t, ErrCacheUnavailable, cacher.Set(serviceName, ctx, "key", "value", time.Minute))
+	})
+
+	t.Run("Closes once the probe reaches the cache again", func(t *testing.T) {
+		assert.NoError(t, mr.Restart())
+
+		assert.Eventually(t, func() bool {
+			return cacher.Status().State == BreakerClosed
+		}, time.Second, 10*time.Millisecond)
+
+		assert.NoError(t, cacher.Set(serviceName, ctx, "key", "value", time.Minute))
+		value, err := cacher.Get(serviceName, ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	})
+}
+
+func TestBreakerInvalidationBus(t *testing.T) {
+	mr := miniredis.NewMiniRedis()
+	assert.NoError(t, mr.Start())
+	port, _ := strconv.Atoi(mr.Port())
+
+	redisCacher := NewCacher(config.RedisClientConfig{Host: mr.Host(), Port: port, DialTimeout: 50 * time.Millisecond}, testUtils.NewAppTracer())
+	cacher := NewBreakerCacher(redisCacher, config.BreakerConfig{Failures: 1, ProbeInterval: time.Minute}, testUtils.NewAppTracer())
+	bus := NewInvalidationBus(cacher, testUtils.NewAppTracer())
+	defer bus.Close()
+	ctx := testUtils.CreateTestContext()
+	invalidation := Invalidation{Namespace: "albums", Keys: []string{"1"}}
+
+	assert.NoError(t, bus.Publish(serviceName, ctx, invalidation))
+
+	mr.Close()
+	assert.Equal(t, ErrCacheGeneric, bus.Publish(serviceName, ctx, invalidation))
+	assert.Equal(t, BreakerOpen, cacher.Status().State, "Failed publishes should open the breaker")
+
+	startTime := time.Now()
+	assert.Equal(t, ErrCacheUnavailable, bus.Publish(serviceName, ctx, invalidation))
+	assert.Less(t, time.Since(startTime), 10*time.Millisecond, "An open breaker should not wait for redis")
+}
+
+func TestTieredCacheStatus(t *testing.T) {
+	tracer := testUtils.NewAppTracer()
+
+	t.Run("Reports the breaker of L2", func(t *testing.T) {
+		remote := NewBreakerCacher(NewMemoryCacher(10, 0, tracer), config.BreakerConfig{}, tracer)
+		cacher := NewTieredCacher(NewMemoryCacher(10, time.Minute, tracer), remote, time.Minute, nil)
+
+		reporter, ok := cacher.(StatusReporter)
+		assert.True(t, ok)
+		assert.Equal(t, remote.Status(), reporter.Status())
+	})
+
+	t.Run("Is closed without a breaker", func(t *testing.T) {
+		cacher := NewTieredCacher(NewMemoryCacher(10, time.Minute, tracer), NewMemoryCacher(10, 0, tracer), time.Minute, nil)
+
+		assert.Equal(t, BreakerStatus{State: BreakerClosed}, cacher.(StatusReporter).Status())
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"example/web-service-gin/app/clientContext"
 	"example/web-service-gin/config"
 	"fmt"
+	"strings"
 	"time"
 	"unicode/utf8"
 
@@ -14,13 +15,41 @@ import (
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Cacher interface {
 	Get(serviceName string, ctx context.Context, key string) (val string, err error)
 	Set(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) error
+	// SetNX sets the value only if the key doesn't exist and reports whether it was set
+	SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error)
+	// Delete removes the keys. Keys that don't exist are ignored
+	Delete(serviceName string, ctx context.Context, keys ...string) error
+	// CompareAndDelete removes the key only while it holds value and reports whether it did.
+	// It releases a lock taken with SetNX without releasing the lock of whoever took it over.
+	CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error)
+	// SetWithTags sets the value and records the key under each tag so it can be purged with InvalidateTags
+	SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error
+	// InvalidateTags deletes every key recorded under the tags
+	InvalidateTags(serviceName string, ctx context.Context, tags ...string) error
+	// MGet returns the values of the keys that exist. Missing keys are left out of the map
+	MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error)
+	// MSet sets every value with the same expiration
+	MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error
+	// TTL returns the remaining time to live of the key, NoExpiration if it never expires or ErrCacheMiss if it doesn't exist
+	TTL(serviceName string, ctx context.Context, key string) (time.Duration, error)
+	// Expire changes the expiration of an existing key, returning ErrCacheMiss if it doesn't exist
+	Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error
+	Exists(serviceName string, ctx context.Context, key string) (bool, error)
+	// Incr atomically adds one to the key, starting from 0 when it doesn't exist, and returns the new value
+	Incr(serviceName string, ctx context.Context, key string) (int64, error)
+	// Decr atomically subtracts one from the key, starting from 0 when it doesn't exist, and returns the new value
+	Decr(serviceName string, ctx context.Context, key string) (int64, error)
 }
 
+// NoExpiration is returned by TTL for keys that never expire
+const NoExpiration time.Duration = -1
+
 type redisCache struct {
 	Client    *redis.Client
 	appTracer appTracer.AppTracer
@@ -29,11 +58,38 @@ type redisCache struct {
 var ErrCacheMiss = apiErrors.NewNotFoundError("")
 var ErrCacheGeneric = apiErrors.NewGenericError("")
 
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverTiered = "tiered"
+)
+
+// NewCacherFromConfig creates the Cacher selected by cache.driver.
+// The memory driver doesn't need redis at all, so it suits local development and tests.
+func NewCacherFromConfig(configFile config.ConfigFile, appTracer appTracer.AppTracer) (Cacher, error) {
+	cfg := configFile.Cache
+	switch cfg.Driver {
+	case "", DriverRedis:
+		return NewBreakerCacher(NewCacher(configFile.Redis, appTracer), cfg.Breaker, appTracer), nil
+	case DriverMemory:
+		return NewMemoryCacher(cfg.MaxEntries, 0, appTracer), nil
+	case DriverTiered:
+		local := NewMemoryCacher(cfg.MaxEntries, cfg.LocalTTL, appTracer)
+		remote := NewBreakerCacher(NewCacher(configFile.Redis, appTracer), cfg.Breaker, appTracer)
+		return NewTieredCacher(local, remote, cfg.LocalTTL, NewInvalidationBus(remote, appTracer)), nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", cfg.Driver)
+	}
+}
+
 func NewCacher(cfg config.RedisClientConfig, appTracer appTracer.AppTracer) Cacher {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
+		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	})
 
 	if err := redisotel.InstrumentTracing(rdb); err != nil {
@@ -67,7 +123,11 @@ func (rc *redisCache) Get(serviceName string, ctx context.Context, key string) (
 		Hit:          val != "",
 	}
 	clientContext.AddCacheCall(ctx, newCacheCall)
-	if err != nil && err != redis.Nil {
+	if err == redis.Nil {
+		span.SetStatus(codes.Ok, "")
+		return "", MapCacheError(&err)
+	}
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return "", MapCacheError(&err)
@@ -117,6 +177,295 @@ func (rc *redisCache) Set(serviceName string, ctx context.Context, key string, v
 	return MapCacheError(&err)
 }
 
+func (rc *redisCache) SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	set, err := rc.Client.SetNX(ctx, key, value, expiration).Result()
+	rc.record(ctx, span, serviceName, "setnx", key, startTime, err, false)
+	span.SetAttributes(attribute.Bool("cache.set", set))
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	if err != nil {
+		return false, MapCacheError(&err)
+	}
+	return set, nil
+}
+
+func (rc *redisCache) Delete(serviceName string, ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	err := rc.Client.Del(ctx, keys...).Err()
+	rc.record(ctx, span, serviceName, "delete", strings.Join(keys, ","), startTime, err, false)
+
+	return MapCacheError(&err)
+}
+
+// compareAndDeleteScript deletes the key only when it holds the value, atomically
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (rc *redisCache) CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error) {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	deleted, err := compareAndDeleteScript.Run(ctx, rc.Client, []string{key}, value).Int()
+	rc.record(ctx, span, serviceName, "compareanddelete", key, startTime, err, deleted > 0)
+	span.SetAttributes(attribute.Bool("cache.deleted", deleted > 0))
+
+	if err != nil {
+		return false, MapCacheError(&err)
+	}
+	return deleted > 0, nil
+}
+
+// setWithTagsScript sets the value of KEYS[1] and adds the key to the tag sets in KEYS[2:].
+// A tag set only ever lives longer: it takes the expiration of the key when that is later than its
+// own and never expires once it tracks a key that doesn't. Keys of a tag written with different
+// expirations, such as pages with a jittered ttl, are therefore all tracked until they expire.
+var setWithTagsScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local current = redis.call("PTTL", KEYS[i])
+	redis.call("SADD", KEYS[i], KEYS[1])
+	if ttl <= 0 then
+		redis.call("PERSIST", KEYS[i])
+	elseif current == -2 or (current >= 0 and current < ttl) then
+		redis.call("PEXPIRE", KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// SetWithTags sets the value and adds the key to a redis set per tag atomically, see setWithTagsScript
+func (rc *redisCache) SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	keys := []string{key}
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	err := setWithTagsScript.Run(ctx, rc.Client, keys, value, expiration.Milliseconds()).Err()
+	rc.record(ctx, span, serviceName, "set", key, startTime, err, false)
+	span.SetAttributes(attribute.StringSlice("cache.tags", tags))
+	span.SetAttributes(attribute.Int("cache.runeCount.", utf8.RuneCountInString(value)))
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	return MapCacheError(&err)
+}
+
+// invalidateTagScript deletes every member of the tag set and the set itself atomically,
+// so a key tagged while the tag is being purged is never left behind untracked.
+var invalidateTagScript = redis.NewScript(`
+local count = 0
+for _, tag in ipairs(KEYS) do
+	local keys = redis.call("SMEMBERS", tag)
+	for _, key in ipairs(keys) do
+		count = count + redis.call("DEL", key)
+	end
+	redis.call("DEL", tag)
+end
+return count
+`)
+
+func (rc *redisCache) InvalidateTags(serviceName string, ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagKeys = append(tagKeys, tagKey(tag))
+	}
+	deleted, err := invalidateTagScript.Run(ctx, rc.Client, tagKeys).Int()
+	rc.record(ctx, span, serviceName, "invalidate", strings.Join(tagKeys, ","), startTime, err, false)
+	span.SetAttributes(attribute.Int("cache.deletedKeys", deleted))
+
+	return MapCacheError(&err)
+}
+
+func (rc *redisCache) MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	results, err := rc.Client.MGet(ctx, keys...).Result()
+	if err == nil {
+		for i, result := range results {
+			if value, ok := result.(string); ok {
+				values[keys[i]] = value
+			}
+		}
+	}
+	rc.record(ctx, span, serviceName, "mget", strings.Join(keys, ","), startTime, err, len(values) > 0)
+	span.SetAttributes(attribute.Int("cache.requestedKeys", len(keys)))
+	span.SetAttributes(attribute.Int("cache.hits", len(values)))
+
+	if err != nil {
+		return nil, MapCacheError(&err)
+	}
+	return values, nil
+}
+
+// MSet writes every key in one pipeline since redis MSET cannot set an expiration
+func (rc *redisCache) MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	keys := make([]string, 0, len(values))
+	_, err := rc.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			keys = append(keys, key)
+			pipe.Set(ctx, key, value, expiration)
+		}
+		return nil
+	})
+	rc.record(ctx, span, serviceName, "mset", strings.Join(keys, ","), startTime, err, false)
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	return MapCacheError(&err)
+}
+
+func (rc *redisCache) TTL(serviceName string, ctx context.Context, key string) (time.Duration, error) {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	ttl, err := rc.Client.TTL(ctx, key).Result()
+	// redis answers -2 for a missing key and -1 for a key without expiration
+	missing := err == nil && ttl == -2
+	rc.record(ctx, span, serviceName, "ttl", key, startTime, err, err == nil && !missing)
+
+	switch {
+	case err != nil:
+		return 0, MapCacheError(&err)
+	case missing:
+		return 0, ErrCacheMiss
+	case ttl < 0:
+		return NoExpiration, nil
+	default:
+		return ttl, nil
+	}
+}
+
+func (rc *redisCache) Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	found, err := rc.Client.Expire(ctx, key, expiration).Result()
+	rc.record(ctx, span, serviceName, "expire", key, startTime, err, found)
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	if err != nil {
+		return MapCacheError(&err)
+	}
+	if !found {
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+func (rc *redisCache) Exists(serviceName string, ctx context.Context, key string) (bool, error) {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	count, err := rc.Client.Exists(ctx, key).Result()
+	rc.record(ctx, span, serviceName, "exists", key, startTime, err, count > 0)
+
+	if err != nil {
+		return false, MapCacheError(&err)
+	}
+	return count > 0, nil
+}
+
+func (rc *redisCache) Incr(serviceName string, ctx context.Context, key string) (int64, error) {
+	return rc.incrBy(serviceName, ctx, "incr", key, 1)
+}
+
+func (rc *redisCache) Decr(serviceName string, ctx context.Context, key string) (int64, error) {
+	return rc.incrBy(serviceName, ctx, "decr", key, -1)
+}
+
+func (rc *redisCache) incrBy(serviceName string, ctx context.Context, action string, key string, delta int64) (int64, error) {
+	startTime := time.Now()
+	ctx, span := rc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	value, err := rc.Client.IncrBy(ctx, key, delta).Result()
+	rc.record(ctx, span, serviceName, action, key, startTime, err, false)
+
+	if err != nil {
+		return 0, MapCacheError(&err)
+	}
+	span.SetAttributes(attribute.Int64("cache.value", value))
+	return value, nil
+}
+
+// record adds the call to the client context and sets the outcome and common attributes on the span
+func (rc *redisCache) record(ctx context.Context, span trace.Span, serviceName string, action string, key string, startTime time.Time, err error, hit bool) {
+	if err == redis.Nil {
+		err = nil
+	}
+	recordCacheCall(ctx, span, "redis", serviceName, action, key, startTime, err, hit)
+}
+
+// recordCacheCall adds the call to the client context and sets the outcome and common attributes on the span.
+// Every Cacher implementation records its calls through here so they look the same in logs and traces.
+func recordCacheCall(ctx context.Context, span trace.Span, cacheName string, serviceName string, action string, key string, startTime time.Time, err error, hit bool) {
+	clientContext.AddCacheCall(ctx, clientContext.CacheCall{
+		ServiceTransaction: clientContext.ServiceTransaction{
+			ServiceName: serviceName,
+			SpanId:      span.SpanContext().TraceID().String(),
+		},
+		Action:       action,
+		ResponseTime: time.Since(startTime),
+		Key:          key,
+		Error:        err,
+		Hit:          hit,
+	})
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.SetAttributes(attribute.String("cache.name", cacheName))
+	span.SetAttributes(attribute.String("cache.action", action))
+	span.SetAttributes(attribute.String("cache.key", key))
+}
+
 func MapCacheError(err *error) error {
 	switch {
 	case *err == redis.Nil:
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"example/web-service-gin/app/clientContext"
 	"example/web-service-gin/config"
 	"example/web-service-gin/testUtils"
 	"strconv"
@@ -90,3 +91,252 @@ func TestSet(t *testing.T) {
 	ttl := mr.TTL(key)
 	assert.InDelta(t, expiration.Seconds(), ttl.Seconds(), 1)
 }
+
+func TestDelete(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	mr.Set("first", "1")
+	mr.Set("second", "2")
+	mr.Set("third", "3")
+
+	ctx := testUtils.CreateTestContext()
+	err := cacher.Delete(serviceName, ctx, "first", "second", "missing")
+	assert.NoError(t, err)
+
+	assert.False(t, mr.Exists("first"))
+	assert.False(t, mr.Exists("second"))
+	assert.True(t, mr.Exists("third"))
+
+	t.Run("Test Delete with Redis error", func(t *testing.T) {
+		mr.Close()
+		err := cacher.Delete(serviceName, ctx, "third")
+		assert.Equal(t, ErrCacheGeneric, err)
+	})
+}
+
+func TestTags(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	expiration := time.Minute
+
+	assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:1", "one", expiration, "albums"))
+	assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:2", "two", expiration, "albums", "artist:miles"))
+	assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "other", "three", expiration, "other"))
+
+	members, err := mr.Members(tagKey("albums"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"page:1", "page:2"}, members)
+	assert.InDelta(t, expiration.Seconds(), mr.TTL(tagKey("albums")).Seconds(), 1)
+
+	err = cacher.InvalidateTags(serviceName, ctx, "albums")
+	assert.NoError(t, err)
+
+	assert.False(t, mr.Exists("page:1"))
+	assert.False(t, mr.Exists("page:2"))
+	assert.False(t, mr.Exists(tagKey("albums")))
+	assert.True(t, mr.Exists("other"))
+
+	t.Run("Invalidating an unknown tag", func(t *testing.T) {
+		assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "unknown"))
+	})
+
+	t.Run("The tag outlives keys written with a shorter expiration after it", func(t *testing.T) {
+		assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:long", "long", 2*time.Minute, "jittered"))
+		assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:short", "short", time.Minute, "jittered"))
+		assert.InDelta(t, (2 * time.Minute).Seconds(), mr.TTL(tagKey("jittered")).Seconds(), 1)
+
+		mr.FastForward(90 * time.Second)
+		assert.False(t, mr.Exists("page:short"))
+		assert.True(t, mr.Exists(tagKey("jittered")))
+
+		assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "jittered"))
+		assert.False(t, mr.Exists("page:long"))
+	})
+
+	t.Run("Jittered keys are all invalidated", func(t *testing.T) {
+		var longest time.Duration
+		for i := range 20 {
+			key := "jittered:" + strconv.Itoa(i)
+			assert.NoError(t, cacher.SetWithTags(serviceName, ctx, key, "page", jitter(time.Minute, 0.5), "jittered"))
+			longest = max(longest, mr.TTL(key))
+		}
+		assert.Equal(t, longest, mr.TTL(tagKey("jittered")))
+
+		assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "jittered"))
+		for i := range 20 {
+			assert.False(t, mr.Exists("jittered:"+strconv.Itoa(i)))
+		}
+	})
+
+	t.Run("The tag of a key that never expires never expires", func(t *testing.T) {
+		assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:expiring", "one", time.Minute, "persistent"))
+		assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:forever", "two", 0, "persistent"))
+		assert.NoError(t, cacher.SetWithTags(serviceName, ctx, "page:later", "three", time.Minute, "persistent"))
+
+		assert.Zero(t, mr.TTL(tagKey("persistent")))
+		assert.Zero(t, mr.TTL("page:forever"))
+	})
+}
+
+func TestMGetAndMSet(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	expiration := time.Minute
+
+	err := cacher.MSet(serviceName, ctx, map[string]string{"first": "1", "second": "2"}, expiration)
+	assert.NoError(t, err)
+	assert.InDelta(t, expiration.Seconds(), mr.TTL("first").Seconds(), 1)
+	assert.InDelta(t, expiration.Seconds(), mr.TTL("second").Seconds(), 1)
+
+	values, err := cacher.MGet(serviceName, ctx, "first", "missing", "second")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"first": "1", "second": "2"}, values)
+
+	t.Run("Test MGet without keys", func(t *testing.T) {
+		values, err := cacher.MGet(serviceName, ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("Test MGet with Redis error", func(t *testing.T) {
+		mr.Close()
+		_, err := cacher.MGet(serviceName, ctx, "first")
+		assert.Equal(t, ErrCacheGeneric, err)
+	})
+}
+
+func TestTTLAndExpire(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	mr.Set("persistent", "value")
+
+	ttl, err := cacher.TTL(serviceName, ctx, "persistent")
+	assert.NoError(t, err)
+	assert.Equal(t, NoExpiration, ttl)
+
+	err = cacher.Expire(serviceName, ctx, "persistent", time.Minute)
+	assert.NoError(t, err)
+
+	ttl, err = cacher.TTL(serviceName, ctx, "persistent")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), ttl.Seconds(), 1)
+
+	_, err = cacher.TTL(serviceName, ctx, "missing")
+	assert.Equal(t, ErrCacheMiss, err)
+
+	err = cacher.Expire(serviceName, ctx, "missing", time.Minute)
+	assert.Equal(t, ErrCacheMiss, err)
+}
+
+func TestExists(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	mr.Set("present", "value")
+
+	exists, err := cacher.Exists(serviceName, ctx, "present")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = cacher.Exists(serviceName, ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	t.Run("Test Exists with Redis error", func(t *testing.T) {
+		mr.Close()
+		_, err := cacher.Exists(serviceName, ctx, "present")
+		assert.Equal(t, ErrCacheGeneric, err)
+	})
+}
+
+func TestIncrAndDecr(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+
+	value, err := cacher.Incr(serviceName, ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	value, err = cacher.Incr(serviceName, ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), value)
+
+	value, err = cacher.Decr(serviceName, ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	t.Run("Test Incr on a value that is not a number", func(t *testing.T) {
+		mr.Set("text", "abc")
+		_, err := cacher.Incr(serviceName, ctx, "text")
+		assert.Equal(t, ErrCacheGeneric, err)
+	})
+}
+
+func TestCacheCallsAreRecorded(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	mr.Set("present", "value")
+
+	cacher.Exists(serviceName, ctx, "present")
+	cacher.Incr(serviceName, ctx, "counter")
+
+	calls := clientContext.GetClientContext(ctx).CacheCalls()
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "exists", calls[0].Action)
+	assert.True(t, calls[0].Hit)
+	assert.Equal(t, "incr", calls[1].Action)
+	assert.Equal(t, "counter", calls[1].Key)
+}
+
+func TestSetNX(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+
+	set, err := cacher.SetNX(serviceName, ctx, "lock", "first", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	set, err = cacher.SetNX(serviceName, ctx, "lock", "second", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, set)
+
+	value, _ := mr.Get("lock")
+	assert.Equal(t, "first", value)
+	assert.InDelta(t, time.Minute.Seconds(), mr.TTL("lock").Seconds(), 1)
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	mr.Set("lock", "owner")
+
+	deleted, err := cacher.CompareAndDelete(serviceName, ctx, "lock", "someone else")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	assert.True(t, mr.Exists("lock"))
+
+	deleted, err = cacher.CompareAndDelete(serviceName, ctx, "lock", "owner")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.False(t, mr.Exists("lock"))
+
+	deleted, err = cacher.CompareAndDelete(serviceName, ctx, "lock", "owner")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// A generation counts the invalidations of a namespace. Keys built at a generation are only read
+// until the next BumpGeneration, so a value loaded before an invalidation but stored after it, by a
+// load that read the source before the write committed, is stored under a key nobody reads anymore
+// instead of being served until it expires. Tags still purge the old entries to free the memory.
+
+// GenerationKey is the key of the counter of the namespace. It shares the prefix of the entries,
+// so purging the prefix resets the generation along with them.
+func (kb KeyBuilder) GenerationKey() string {
+	return kb.Prefix() + "generation"
+}
+
+// Generation returns the current generation of the namespace, 0 until it is first bumped.
+// Read it before loading a value, then build the key of the value with BuildAt.
+func (kb KeyBuilder) Generation(cacher Cacher, serviceName string, ctx context.Context) (int64, error) {
+	value, err := cacher.Get(serviceName, ctx, kb.GenerationKey())
+	if errors.Is(err, ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// BumpGeneration moves the namespace to its next generation, so the keys built at the previous
+// ones are no longer read
+func (kb KeyBuilder) BumpGeneration(cacher Cacher, serviceName string, ctx context.Context) error {
+	_, err := cacher.Incr(serviceName, ctx, kb.GenerationKey())
+	return err
+}
+
+// generationParams are the parameters hashed by BuildAt
+type generationParams struct {
+	Generation int64 `json:"generation"`
+	Params     any   `json:"params"`
+}
+
+// BuildAt hashes the parameters and the generation into a key, see Build
+func (kb KeyBuilder) BuildAt(params any, generation int64) (string, error) {
+	return kb.Build(generationParams{Generation: generation, Params: params})
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"example/web-service-gin/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneration(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	builder := NewKeyBuilder("albums:list", 1)
+
+	generation, err := builder.Generation(cacher, serviceName, ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, generation)
+	before, _ := builder.BuildAt(keyParams{Artist: "Miles Davis", Page: 1}, generation)
+
+	assert.NoError(t, builder.BumpGeneration(cacher, serviceName, ctx))
+
+	generation, err = builder.Generation(cacher, serviceName, ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), generation)
+	after, _ := builder.BuildAt(keyParams{Artist: "Miles Davis", Page: 1}, generation)
+	assert.NotEqual(t, before, after, "Keys built before the bump should no longer be read")
+	assert.Contains(t, after, builder.Prefix(), "Keys keep the prefix of the namespace")
+
+	t.Run("A generation that isn't a number is an error", func(t *testing.T) {
+		mr.Set(builder.GenerationKey(), "corrupted")
+		_, err := builder.Generation(cacher, serviceName, ctx)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"example/web-service-gin/app/appTracer"
+	"example/web-service-gin/app/clientContext"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Invalidation asks every instance to evict entries it holds in process.
+// Subscribers only receive the invalidations published to their namespace.
+type Invalidation struct {
+	Namespace string   `json:"namespace"`
+	Keys      []string `json:"keys,omitempty"`
+	Prefixes  []string `json:"prefixes,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// Origin identifies the publisher so an instance can skip what it already evicted itself
+	Origin string `json:"origin,omitempty"`
+}
+
+// Matches reports whether the key is evicted by the invalidation, either by name or by prefix
+func (i Invalidation) Matches(key string) bool {
+	for _, k := range i.Keys {
+		if k == key {
+			return true
+		}
+	}
+	for _, prefix := range i.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type InvalidationHandler func(ctx context.Context, invalidation Invalidation)
+
+// InvalidationBus broadcasts evictions of process local data to every running instance
+type InvalidationBus interface {
+	Publish(serviceName string, ctx context.Context, invalidation Invalidation) error
+	// Subscribe calls handler with every invalidation of the namespace, including the ones
+	// published by this instance, until the returned function is called
+	Subscribe(namespace string, handler InvalidationHandler) (unsubscribe func())
+	Close() error
+}
+
+// NewInvalidationBus returns a bus sharing the redis client of the cacher when it is backed by redis.
+// Behind a breaker, invalidations are published through it like the calls of the cacher.
+// Caches that live only in this process get an in-memory bus, as there is no other instance to notify.
+func NewInvalidationBus(cacher Cacher, appTracer appTracer.AppTracer) InvalidationBus {
+	switch c := cacher.(type) {
+	case *redisCache:
+		return NewRedisInvalidationBus(c.Client, appTracer)
+	case *breakerCache:
+		return &breakerBus{InvalidationBus: NewInvalidationBus(c.next, appTracer), breaker: c}
+	case *tieredCache:
+		if c.bus != nil {
+			return c.bus
+		}
+		return NewInvalidationBus(c.remote, appTracer)
+	default:
+		return NewMemoryInvalidationBus(appTracer)
+	}
+}
+
+// NewOrigin returns a random id identifying a publisher in Invalidation.Origin
+func NewOrigin() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// subscribers keeps the handlers of each namespace. It is shared by the bus implementations.
+type subscribers struct {
+	mu       sync.RWMutex
+	nextId   int
+	handlers map[string]map[int]InvalidationHandler
+}
+
+func (s *subscribers) add(namespace string, handler InvalidationHandler) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = map[string]map[int]InvalidationHandler{}
+	}
+	if s.handlers[namespace] == nil {
+		s.handlers[namespace] = map[int]InvalidationHandler{}
+	}
+	id := s.nextId
+	s.nextId++
+	s.handlers[namespace][id] = handler
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.handlers[namespace], id)
+		if len(s.handlers[namespace]) == 0 {
+			delete(s.handlers, namespace)
+		}
+	}
+}
+
+func (s *subscribers) of(namespace string) []InvalidationHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	handlers := make([]InvalidationHandler, 0, len(s.handlers[namespace]))
+	for _, handler := range s.handlers[namespace] {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
+// deliver traces the receipt of the invalidation and calls the handlers of its namespace.
+// Received invalidations don't belong to any request, so they get a ClientContext of their own.
+func (s *subscribers) deliver(tracer appTracer.AppTracer, busName string, invalidation Invalidation) {
+	handlers := s.of(invalidation.Namespace)
+	if len(handlers) == 0 {
+		return
+	}
+	startTime := time.Now()
+	ctx := context.WithValue(context.Background(), clientContext.ClientContextKey, &clientContext.ClientContext{})
+	ctx, span := tracer.CreateSpan(ctx, invalidationServiceName)
+	defer span.End()
+
+	for _, handler := range handlers {
+		handler(ctx, invalidation)
+	}
+	recordInvalidation(ctx, span, busName, invalidationServiceName, "receive", invalidation, startTime, nil)
+	span.SetAttributes(attribute.Int("cache.subscribers", len(handlers)))
+}
+
+const invalidationServiceName = "cacheInvalidation"
+
+func recordInvalidation(ctx context.Context, span trace.Span, busName string, serviceName string, action string, invalidation Invalidation, startTime time.Time, err error) {
+	recordCacheCall(ctx, span, busName, serviceName, action, invalidation.Namespace, startTime, err, false)
+	span.SetAttributes(attribute.String("cache.origin", invalidation.Origin))
+	span.SetAttributes(attribute.StringSlice("cache.keys", invalidation.Keys))
+	span.SetAttributes(attribute.StringSlice("cache.prefixes", invalidation.Prefixes))
+	span.SetAttributes(attribute.StringSlice("cache.tags", invalidation.Tags))
+}
+
+// redisInvalidationBus broadcasts invalidations over a redis pub/sub channel.
+// The subscription is opened with the first subscriber and shared by all of them.
+type redisInvalidationBus struct {
+	client      *redis.Client
+	channel     string
+	appTracer   appTracer.AppTracer
+	subscribers subscribers
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+}
+
+func NewRedisInvalidationBus(client *redis.Client, appTracer appTracer.AppTracer) InvalidationBus {
+	return &redisInvalidationBus{
+		client:    client,
+		channel:   invalidationChannel(),
+		appTracer: appTracer,
+	}
+}
+
+func (rb *redisInvalidationBus) Publish(serviceName string, ctx context.Context, invalidation Invalidation) error {
+	startTime := time.Now()
+	ctx, span := rb.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	payload, err := json.Marshal(invalidation)
+	if err == nil {
+		err = rb.client.Publish(ctx, rb.channel, payload).Err()
+	}
+	recordInvalidation(ctx, span, "redis", serviceName, "publish", invalidation, startTime, err)
+
+	return MapCacheError(&err)
+}
+
+func (rb *redisInvalidationBus) Subscribe(namespace string, handler InvalidationHandler) func() {
+	unsubscribe := rb.subscribers.add(namespace, handler)
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.pubsub == nil {
+		rb.pubsub = rb.client.Subscribe(context.Background(), rb.channel)
+		go rb.listen(rb.pubsub.Channel())
+	}
+	return unsubscribe
+}
+
+// listen delivers messages until the subscription is closed.
+// The redis client reconnects on its own; messages published while disconnected are lost,
+// which is bounded by the expiration of the process local entries.
+func (rb *redisInvalidationBus) listen(messages <-chan *redis.Message) {
+	for message := range messages {
+		var invalidation Invalidation
+		if err := json.Unmarshal([]byte(message.Payload), &invalidation); err != nil {
+			continue
+		}
+		rb.subscribers.deliver(rb.appTracer, "redis", invalidation)
+	}
+}
+
+func (rb *redisInvalidationBus) Close() error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.pubsub == nil {
+		return nil
+	}
+	err := rb.pubsub.Close()
+	rb.pubsub = nil
+	return err
+}
+
+// memoryInvalidationBus delivers invalidations to the subscribers of this process synchronously.
+// It is used when there is no redis and in tests standing in for several instances.
+type memoryInvalidationBus struct {
+	appTracer   appTracer.AppTracer
+	subscribers subscribers
+}
+
+func NewMemoryInvalidationBus(appTracer appTracer.AppTracer) InvalidationBus {
+	return &memoryInvalidationBus{appTracer: appTracer}
+}
+
+func (mb *memoryInvalidationBus) Publish(serviceName string, ctx context.Context, invalidation Invalidation) error {
+	startTime := time.Now()
+	ctx, span := mb.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mb.subscribers.deliver(mb.appTracer, "memory", invalidation)
+	recordInvalidation(ctx, span, "memory", serviceName, "publish", invalidation, startTime, nil)
+
+	return nil
+}
+
+func (mb *memoryInvalidationBus) Subscribe(namespace string, handler InvalidationHandler) func() {
+	return mb.subscribers.add(namespace, handler)
+}
+
+func (mb *memoryInvalidationBus) Close() error {
+	return nil
+}
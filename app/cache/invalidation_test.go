@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"example/web-service-gin/testUtils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidationMatches(t *testing.T) {
+	invalidation := Invalidation{Keys: []string{"albums:1"}, Prefixes: []string{"albums:list:"}}
+
+	assert.True(t, invalidation.Matches("albums:1"))
+	assert.True(t, invalidation.Matches("albums:list:v1:abc"))
+	assert.False(t, invalidation.Matches("albums:2"))
+}
+
+func TestMemoryInvalidationBus(t *testing.T) {
+	bus := NewMemoryInvalidationBus(testUtils.NewAppTracer())
+	ctx := testUtils.CreateTestContext()
+
+	var received []Invalidation
+	unsubscribe := bus.Subscribe("albums", func(ctx context.Context, invalidation Invalidation) {
+		received = append(received, invalidation)
+	})
+	bus.Subscribe("other", func(ctx context.Context, invalidation Invalidation) {
+		t.Errorf("Unexpected invalidation of another namespace: %v", invalidation)
+	})
+
+	assert.NoError(t, bus.Publish(serviceName, ctx, Invalidation{Namespace: "albums", Keys: []string{"a"}}))
+	assert.Equal(t, []Invalidation{{Namespace: "albums", Keys: []string{"a"}}}, received)
+
+	unsubscribe()
+	assert.NoError(t, bus.Publish(serviceName, ctx, Invalidation{Namespace: "albums", Keys: []string{"b"}}))
+	assert.Len(t, received, 1, "Unsubscribed handlers should not be called")
+}
+
+func TestRedisInvalidationBus(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	tracer := testUtils.NewAppTracer()
+	publisher := NewInvalidationBus(cacher, tracer)
+	subscriber := NewInvalidationBus(cacher, tracer)
+	defer publisher.Close()
+	defer subscriber.Close()
+
+	var mu sync.Mutex
+	var received []Invalidation
+	subscriber.Subscribe("albums", func(ctx context.Context, invalidation Invalidation) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, invalidation)
+	})
+	// Wait for the subscription to be registered before publishing
+	assert.Eventually(t, func() bool {
+		return len(mr.PubSubChannels("")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	sent := Invalidation{Namespace: "albums", Prefixes: []string{"albums:list:"}, Origin: "instance-1"}
+	assert.NoError(t, publisher.Publish(serviceName, testUtils.CreateTestContext(), Invalidation{Namespace: "other", Keys: []string{"x"}}))
+	assert.NoError(t, publisher.Publish(serviceName, testUtils.CreateTestContext(), sent))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1 && received[0].Origin == sent.Origin
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []Invalidation{sent}, received)
+}
+
+func TestTieredCacheInvalidatesOtherInstances(t *testing.T) {
+	mr, remote := setupTestRedis(t)
+	defer mr.Close()
+
+	bus := NewMemoryInvalidationBus(testUtils.NewAppTracer())
+	firstLocal, _ := setupTestMemory(10, time.Minute)
+	secondLocal, _ := setupTestMemory(10, time.Minute)
+	first := NewTieredCacher(firstLocal, remote, time.Minute, bus)
+	second := NewTieredCacher(secondLocal, remote, time.Minute, bus)
+	ctx := testUtils.CreateTestContext()
+
+	t.Run("Writes evict the other instance", func(t *testing.T) {
+		assert.NoError(t, first.Set(serviceName, ctx, "album", "old", time.Hour))
+		value, _ := second.Get(serviceName, ctx, "album")
+		assert.Equal(t, "old", value)
+
+		assert.NoError(t, first.Set(serviceName, ctx, "album", "new", time.Hour))
+		value, _ = second.Get(serviceName, ctx, "album")
+		assert.Equal(t, "new", value)
+
+		value, err := firstLocal.Get(serviceName, ctx, "album")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", value, "The publisher keeps its own local copy")
+	})
+
+	t.Run("Tags are invalidated on the other instance", func(t *testing.T) {
+		second.SetWithTags(serviceName, ctx, "page", "value", time.Hour, "pages")
+		assert.NoError(t, first.InvalidateTags(serviceName, ctx, "pages"))
+
+		_, err := secondLocal.Get(serviceName, ctx, "page")
+		assert.Equal(t, ErrCacheMiss, err)
+	})
+
+	t.Run("Tags are invalidated on the instance that read the value through", func(t *testing.T) {
+		assert.NoError(t, first.SetWithTags(serviceName, ctx, "list", "old", time.Hour, "lists"))
+		value, _ := second.Get(serviceName, ctx, "list")
+		assert.Equal(t, "old", value)
+
+		assert.NoError(t, first.InvalidateTags(serviceName, ctx, "lists"))
+
+		_, err := secondLocal.Get(serviceName, ctx, "list")
+		assert.Equal(t, ErrCacheMiss, err)
+		_, err = second.Get(serviceName, ctx, "list")
+		assert.Equal(t, ErrCacheMiss, err)
+	})
+
+	t.Run("Prefixes evict every matching local key", func(t *testing.T) {
+		secondLocal.Set(serviceName, ctx, "albums:list:1", "a", time.Hour)
+		secondLocal.Set(serviceName, ctx, "albums:list:2", "b", time.Hour)
+		secondLocal.Set(serviceName, ctx, "albums:1", "c", time.Hour)
+
+		bus.Publish(serviceName, ctx, Invalidation{Namespace: tieredNamespace, Prefixes: []string{"albums:list:"}})
+
+		values, _ := secondLocal.MGet(serviceName, ctx, "albums:list:1", "albums:list:2", "albums:1")
+		assert.Equal(t, map[string]string{"albums:1": "c"}, values)
+	})
+}
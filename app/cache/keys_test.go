@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keyParams struct {
+	Artist string
+	Page   int
+}
+
+type reorderedKeyParams struct {
+	Page   int
+	Artist string
+}
+
+func TestKeyBuilder(t *testing.T) {
+	defer SetKeyPrefix("")
+
+	builder := NewKeyBuilder("albums:list", 1)
+
+	t.Run("Key has prefix, namespace and version", func(t *testing.T) {
+		SetKeyPrefix("album-store")
+		key, err := builder.Build(keyParams{Artist: "Miles Davis", Page: 1})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(key, "album-store:albums:list:v1:"), key)
+		assert.Len(t, strings.TrimPrefix(key, builder.Prefix()), 64)
+	})
+
+	t.Run("Field order does not change the key", func(t *testing.T) {
+		first, _ := builder.Build(keyParams{Artist: "Miles Davis", Page: 1})
+		second, _ := builder.Build(reorderedKeyParams{Page: 1, Artist: "Miles Davis"})
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("Every field changes the key", func(t *testing.T) {
+		first, _ := builder.Build(keyParams{Artist: "Miles Davis", Page: 1})
+		second, _ := builder.Build(keyParams{Artist: "Miles Davis", Page: 2})
+		third, _ := builder.Build(keyParams{Artist: "John Coltrane", Page: 1})
+		assert.NotEqual(t, first, second)
+		assert.NotEqual(t, first, third)
+	})
+
+	t.Run("Version changes the key", func(t *testing.T) {
+		first, _ := builder.Build(keyParams{Artist: "Miles Davis", Page: 1})
+		second, _ := NewKeyBuilder("albums:list", 2).Build(keyParams{Artist: "Miles Davis", Page: 1})
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Unsupported parameters", func(t *testing.T) {
+		_, err := builder.Build(make(chan int))
+		assert.Error(t, err)
+	})
+}
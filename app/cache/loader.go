@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"example/web-service-gin/app/apiErrors"
+	"example/web-service-gin/app/clientContext"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	lockKeySuffix       = ":lock"
+	defaultLockTimeout  = 5 * time.Second
+	lockPollingInterval = 50 * time.Millisecond
+	defaultLoadTimeout  = 10 * time.Second
+)
+
+// loadGroup coalesces concurrent loads of the same key within this process.
+var loadGroup singleflight.Group
+
+// ErrLoadTimeout is returned when the deadline of the caller or the LoadTimeout of the load passes first
+var ErrLoadTimeout = apiErrors.NewWithCode(apiErrors.TimeoutErrorCode, "timed out loading the value")
+
+// ErrLoadCanceled is returned when the caller is canceled while waiting for the load
+var ErrLoadCanceled = apiErrors.NewWithCode(apiErrors.QueryCanceledErrorCode, "canceled loading the value")
+
+// LoadOptions tune how GetOrLoad reads, stores and coalesces an entry.
+type LoadOptions struct {
+	// Codec encodes the stored entry. Defaults to JSONCodec
+	Codec Codec
+	// Tags are recorded for the stored entry so it can be purged with InvalidateTags
+	Tags []string
+	// Jitter spreads the expiration by up to this fraction of the ttl in both directions,
+	// so entries written together don't all expire on the same second
+	Jitter float64
+	// StaleWhileRevalidate keeps serving an expired entry for this long while it is
+	// reloaded in the background. Zero disables it
+	StaleWhileRevalidate time.Duration
+	// Lock takes a redis lock before loading, so only one instance loads the key while
+	// the others wait for its result instead of hitting the database as well
+	Lock bool
+	// LockTimeout is how long the lock is held at most and how long other instances wait for it
+	LockTimeout time.Duration
+	// LoadTimeout bounds a load. Loads are shared between callers, so they don't run on the
+	// context of any of them and are only bounded by this timeout. Defaults to 10s.
+	// With Lock it is kept under LockTimeout, so a load never outlives its lock.
+	LoadTimeout time.Duration
+}
+
+type LoadOption func(*LoadOptions)
+
+func WithCodec(codec Codec) LoadOption {
+	return func(o *LoadOptions) {
+		o.Codec = codec
+	}
+}
+
+func WithTags(tags ...string) LoadOption {
+	return func(o *LoadOptions) {
+		o.Tags = tags
+	}
+}
+
+func WithJitter(fraction float64) LoadOption {
+	return func(o *LoadOptions) {
+		o.Jitter = fraction
+	}
+}
+
+func WithStaleWhileRevalidate(window time.Duration) LoadOption {
+	return func(o *LoadOptions) {
+		o.StaleWhileRevalidate = window
+	}
+}
+
+func WithLock(timeout time.Duration) LoadOption {
+	return func(o *LoadOptions) {
+		o.Lock = true
+		o.LockTimeout = timeout
+	}
+}
+
+func WithLoadTimeout(timeout time.Duration) LoadOption {
+	return func(o *LoadOptions) {
+		o.LoadTimeout = timeout
+	}
+}
+
+func newLoadOptions(opts []LoadOption) LoadOptions {
+	options := LoadOptions{Codec: JSONCodec}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Lock && options.LockTimeout <= 0 {
+		options.LockTimeout = defaultLockTimeout
+	}
+	if options.LoadTimeout <= 0 {
+		options.LoadTimeout = defaultLoadTimeout
+	}
+	if options.Lock && options.LoadTimeout >= options.LockTimeout {
+		// Leave part of the lock to store the value before another instance may take the lock
+		options.LoadTimeout = options.LockTimeout * 9 / 10
+	}
+	return options
+}
+
+// revalidatingEntry is what GetOrLoad stores when stale-while-revalidate is enabled.
+// The entry lives in the cache for ttl + window but is only fresh until FreshUntil.
+type revalidatingEntry[T any] struct {
+	Value      T         `json:"value"`
+	FreshUntil time.Time `json:"freshUntil"`
+}
+
+// GetOrLoad returns the cached value of the key, calling loader and caching its result on a miss.
+//
+// Concurrent misses of the same key in this process share a single call to loader. With WithLock,
+// instances coordinate through a redis lock so only one of them calls loader while the others
+// wait for the value to appear. Errors from loader are returned and never cached.
+//
+// The shared load runs on a context detached from the callers, bounded by LoadTimeout, so a caller
+// going away doesn't fail the others. Each caller stops waiting when its own context is done,
+// with ErrLoadTimeout or ErrLoadCanceled.
+// The calls of the load are added to the ClientContext of every caller that waited for it, so they
+// show up in its access log; the calls of a background refresh aren't recorded on any request.
+//
+// Cache errors are treated as misses: the value is loaded from the source and the request
+// still succeeds when the cache is unavailable.
+func GetOrLoad[T any](cacher Cacher, serviceName string, ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error), opts ...LoadOption) (T, error) {
+	options := newLoadOptions(opts)
+
+	if value, fresh, err := getLoadedEntry[T](cacher, options, serviceName, ctx, key); err == nil {
+		if !fresh {
+			// Serve the stale value now and refresh it for the next requests
+			loadGroup.DoChan(key, func() (any, error) {
+				loadCtx, cancel := detachLoad(ctx, options)
+				defer cancel()
+				return load(cacher, options, serviceName, loadCtx, key, ttl, loader)
+			})
+		}
+		return value, nil
+	}
+
+	results := loadGroup.DoChan(key, func() (any, error) {
+		loadCtx, cancel := detachLoad(ctx, options)
+		defer cancel()
+		value, err := loadOnce(cacher, options, serviceName, loadCtx, key, ttl, loader)
+		return sharedLoad[T]{value: value, calls: recordedCalls(loadCtx)}, err
+	})
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, mapContextError(ctx.Err())
+	case result := <-results:
+		shared := result.Val.(sharedLoad[T])
+		clientContext.AddCalls(ctx, shared.calls)
+		if result.Err != nil {
+			var zero T
+			return zero, mapContextError(result.Err)
+		}
+		return shared.value, nil
+	}
+}
+
+// mapContextError translates a context ending into the APIError returned to clients, like
+// db.MapDBError does for queries, so a timeout isn't reported as an internal error.
+// Other errors, already mapped by the loader, are returned as is.
+func mapContextError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrLoadTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrLoadCanceled
+	default:
+		return err
+	}
+}
+
+// sharedLoad is the result of a load shared by the callers waiting on it, with the calls it made
+// so each caller records them as its own
+type sharedLoad[T any] struct {
+	value T
+	calls clientContext.Snapshot
+}
+
+// detachLoad returns the context a shared load runs on: the trace and request information of the
+// caller starting it, without its cancellation, bounded by LoadTimeout
+func detachLoad(ctx context.Context, options LoadOptions) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(clientContext.Detach(ctx), options.LoadTimeout)
+}
+
+// recordedCalls returns the calls recorded on the ClientContext of a detached load
+func recordedCalls(ctx context.Context) clientContext.Snapshot {
+	if currentContext, ok := clientContext.FromContext(ctx); ok {
+		return currentContext.Snapshot()
+	}
+	return clientContext.Snapshot{}
+}
+
+// getLoadedEntry reads the entry and reports whether it is still fresh
+func getLoadedEntry[T any](cacher Cacher, options LoadOptions, serviceName string, ctx context.Context, key string) (T, bool, error) {
+	if options.StaleWhileRevalidate <= 0 {
+		value, err := GetWithCodec[T](cacher, options.Codec, serviceName, ctx, key)
+		if err != nil {
+			var zero T
+			return zero, false, err
+		}
+		return *value, true, nil
+	}
+
+	entry, err := GetWithCodec[revalidatingEntry[T]](cacher, options.Codec, serviceName, ctx, key)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return entry.Value, time.Now().Before(entry.FreshUntil), nil
+}
+
+// loadOnce takes the cross instance lock when enabled before loading.
+// When another instance holds the lock it waits for that instance to store the value.
+func loadOnce[T any](cacher Cacher, options LoadOptions, serviceName string, ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	if !options.Lock {
+		return load(cacher, options, serviceName, ctx, key, ttl, loader)
+	}
+
+	lockKey := key + lockKeySuffix
+	// The token tells this lock from the one another instance takes once this one expired,
+	// so releasing it never releases theirs
+	token := newLockToken()
+	acquired, err := cacher.SetNX(serviceName, ctx, lockKey, token, options.LockTimeout)
+	if err == nil && !acquired {
+		if value, found := waitForLoadedEntry[T](cacher, options, serviceName, ctx, key); found {
+			return value, nil
+		}
+	}
+	if acquired {
+		defer cacher.CompareAndDelete(serviceName, ctx, lockKey, token)
+	}
+	return load(cacher, options, serviceName, ctx, key, ttl, loader)
+}
+
+// newLockToken returns a random value identifying the holder of a lock
+func newLockToken() string {
+	token := make([]byte, 16)
+	cryptorand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// waitForLoadedEntry polls the cache until the lock holder stores the value or the lock times out
+func waitForLoadedEntry[T any](cacher Cacher, options LoadOptions, serviceName string, ctx context.Context, key string) (T, bool) {
+	deadline := time.Now().Add(options.LockTimeout)
+	ticker := time.NewTicker(lockPollingInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, false
+		case <-ticker.C:
+			if value, _, err := getLoadedEntry[T](cacher, options, serviceName, ctx, key); err == nil {
+				return value, true
+			}
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// load calls loader and stores its result. A failure to store is recorded by the cacher but not returned.
+func load[T any](cacher Cacher, options LoadOptions, serviceName string, ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	value, err := loader(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	ttl = jitter(ttl, options.Jitter)
+	if options.StaleWhileRevalidate > 0 {
+		entry := revalidatingEntry[T]{Value: value, FreshUntil: time.Now().Add(ttl)}
+		SetWithCodec(cacher, options.Codec, serviceName, ctx, key, entry, ttl+options.StaleWhileRevalidate, options.Tags...)
+	} else {
+		SetWithCodec(cacher, options.Codec, serviceName, ctx, key, value, ttl, options.Tags...)
+	}
+	return value, nil
+}
+
+// jitter returns ttl moved randomly by up to fraction of itself in either direction
+func jitter(ttl time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || ttl <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * fraction
+	return ttl + time.Duration((rand.Float64()*2-1)*spread)
+}
@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/testUtils"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+
+	t.Run("Miss loads and stores the value", func(t *testing.T) {
+		var calls int32
+		loader := func(ctx context.Context) (typedValue, error) {
+			atomic.AddInt32(&calls, 1)
+			return typedValue{Name: "loaded", Count: 1}, nil
+		}
+
+		value, err := GetOrLoad(cacher, serviceName, ctx, "load:miss", time.Minute, loader, WithTags("loaded"))
+		assert.NoError(t, err)
+		assert.Equal(t, typedValue{Name: "loaded", Count: 1}, value)
+		assert.True(t, mr.Exists("load:miss"))
+
+		members, _ := mr.Members(tagKey("loaded"))
+		assert.Equal(t, []string{"load:miss"}, members)
+
+		value, err = GetOrLoad(cacher, serviceName, ctx, "load:miss", time.Minute, loader)
+		assert.NoError(t, err)
+		assert.Equal(t, typedValue{Name: "loaded", Count: 1}, value)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "The second call should be served from the cache")
+	})
+
+	t.Run("Concurrent misses share one load", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		loader := func(ctx context.Context) (typedValue, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return typedValue{Name: "shared"}, nil
+		}
+
+		var wg sync.WaitGroup
+		results := make([]typedValue, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = GetOrLoad(cacher, serviceName, testUtils.CreateTestContext(), "load:concurrent", time.Minute, loader)
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		for _, result := range results {
+			assert.Equal(t, "shared", result.Name)
+		}
+	})
+
+	t.Run("The calls of the load are recorded on every caller", func(t *testing.T) {
+		release := make(chan struct{})
+		loader := func(ctx context.Context) (typedValue, error) {
+			<-release
+			clientContext.AddDatabaseCall(ctx, clientContext.DatabaseCall{Query: "SELECT id FROM albums"})
+			return typedValue{Name: "recorded"}, nil
+		}
+
+		callers := []context.Context{testUtils.CreateTestContext(), testUtils.CreateTestContext()}
+		var wg sync.WaitGroup
+		for _, callerCtx := range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				GetOrLoad(cacher, serviceName, callerCtx, "load:recorded", time.Minute, loader)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for _, callerCtx := range callers {
+			snapshot := clientContext.GetClientContext(callerCtx).Snapshot()
+			assert.Equal(t, []string{"SELECT id FROM albums"}, queriesOf(snapshot))
+			assert.Equal(t, []string{"get", "set"}, actionsOf(snapshot), "The miss of the caller, then the store of the load")
+		}
+	})
+
+	t.Run("A caller going away doesn't fail the others", func(t *testing.T) {
+		release := make(chan struct{})
+		var loadErr error
+		loader := func(ctx context.Context) (typedValue, error) {
+			<-release
+			loadErr = ctx.Err()
+			return typedValue{Name: "shared"}, nil
+		}
+
+		firstCtx, cancel := context.WithCancel(testUtils.CreateTestContext())
+		firstErr := make(chan error)
+		go func() {
+			_, err := GetOrLoad(cacher, serviceName, firstCtx, "load:canceled", time.Minute, loader)
+			firstErr <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		second := make(chan typedValue)
+		go func() {
+			value, _ := GetOrLoad(cacher, serviceName, testUtils.CreateTestContext(), "load:canceled", time.Minute, loader)
+			second <- value
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		assert.Equal(t, ErrLoadCanceled, <-firstErr, "The canceled caller stops waiting")
+		close(release)
+		assert.Equal(t, "shared", (<-second).Name)
+		assert.NoError(t, loadErr, "The load doesn't run on the canceled context")
+		assert.True(t, mr.Exists("load:canceled"))
+	})
+
+	t.Run("Loads are bounded by the load timeout", func(t *testing.T) {
+		_, err := GetOrLoad(cacher, serviceName, ctx, "load:timeout", time.Minute, func(ctx context.Context) (typedValue, error) {
+			<-ctx.Done()
+			return typedValue{}, ctx.Err()
+		}, WithLoadTimeout(10*time.Millisecond))
+		assert.Equal(t, ErrLoadTimeout, err)
+	})
+
+	t.Run("A caller whose deadline passes stops waiting with a timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		deadlineCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := GetOrLoad(cacher, serviceName, deadlineCtx, "load:deadline", time.Minute, func(ctx context.Context) (typedValue, error) {
+			<-release
+			return typedValue{}, nil
+		})
+
+		assert.Equal(t, ErrLoadTimeout, err)
+		assert.Equal(t, http.StatusGatewayTimeout, ErrLoadTimeout.Status)
+	})
+
+	t.Run("Loader errors are returned and not cached", func(t *testing.T) {
+		loadErr := errors.New("database down")
+		_, err := GetOrLoad(cacher, serviceName, ctx, "load:error", time.Minute, func(ctx context.Context) (typedValue, error) {
+			return typedValue{}, loadErr
+		})
+		assert.Equal(t, loadErr, err)
+		assert.False(t, mr.Exists("load:error"))
+	})
+
+	t.Run("Stale entry is served while it is refreshed", func(t *testing.T) {
+		stale := revalidatingEntry[typedValue]{Value: typedValue{Name: "stale"}, FreshUntil: time.Now().Add(-time.Second)}
+		assert.NoError(t, SetJSON(cacher, serviceName, ctx, "load:stale", stale, time.Minute))
+
+		refreshed := make(chan struct{})
+		value, err := GetOrLoad(cacher, serviceName, ctx, "load:stale", time.Minute, func(ctx context.Context) (typedValue, error) {
+			defer close(refreshed)
+			return typedValue{Name: "fresh"}, nil
+		}, WithStaleWhileRevalidate(time.Minute))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "stale", value.Name)
+
+		<-refreshed
+		assert.Eventually(t, func() bool {
+			entry, err := GetJSON[revalidatingEntry[typedValue]](cacher, serviceName, ctx, "load:stale")
+			return err == nil && entry.Value.Name == "fresh"
+		}, time.Second, 10*time.Millisecond)
+		assert.InDelta(t, (2 * time.Minute).Seconds(), mr.TTL("load:stale").Seconds(), 1)
+	})
+
+	t.Run("Waits for the instance holding the lock", func(t *testing.T) {
+		mr.Set("load:locked"+lockKeySuffix, "1")
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			SetJSON(cacher, serviceName, testUtils.CreateTestContext(), "load:locked", typedValue{Name: "from another instance"}, time.Minute)
+		}()
+
+		var calls int32
+		value, err := GetOrLoad(cacher, serviceName, ctx, "load:locked", time.Minute, func(ctx context.Context) (typedValue, error) {
+			atomic.AddInt32(&calls, 1)
+			return typedValue{Name: "loaded locally"}, nil
+		}, WithLock(time.Second))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "from another instance", value.Name)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Releases the lock after loading", func(t *testing.T) {
+		value, err := GetOrLoad(cacher, serviceName, ctx, "load:lock-owner", time.Minute, func(ctx context.Context) (typedValue, error) {
+			assert.True(t, mr.Exists("load:lock-owner"+lockKeySuffix), "The lock should be held while loading")
+			return typedValue{Name: "owner"}, nil
+		}, WithLock(time.Second))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "owner", value.Name)
+		assert.False(t, mr.Exists("load:lock-owner"+lockKeySuffix))
+	})
+
+	t.Run("Doesn't release the lock another instance took over", func(t *testing.T) {
+		lockKey := "load:lock-expired" + lockKeySuffix
+		_, err := GetOrLoad(cacher, serviceName, ctx, "load:lock-expired", time.Minute, func(ctx context.Context) (typedValue, error) {
+			// The lock expired during a slow load and another instance took it
+			mr.Del(lockKey)
+			mr.Set(lockKey, "another instance")
+			return typedValue{Name: "slow"}, nil
+		}, WithLock(time.Second))
+
+		assert.NoError(t, err)
+		value, _ := mr.Get(lockKey)
+		assert.Equal(t, "another instance", value)
+	})
+}
+
+func TestLoadOptions(t *testing.T) {
+	t.Run("Loads are kept under the lock timeout", func(t *testing.T) {
+		options := newLoadOptions([]LoadOption{WithLock(time.Second), WithLoadTimeout(5 * time.Second)})
+		assert.Less(t, options.LoadTimeout, options.LockTimeout)
+
+		options = newLoadOptions([]LoadOption{WithLock(0)})
+		assert.Equal(t, defaultLockTimeout, options.LockTimeout)
+		assert.Less(t, options.LoadTimeout, defaultLockTimeout)
+	})
+
+	t.Run("Shorter load timeouts are kept", func(t *testing.T) {
+		options := newLoadOptions([]LoadOption{WithLock(time.Second), WithLoadTimeout(100 * time.Millisecond)})
+		assert.Equal(t, 100*time.Millisecond, options.LoadTimeout)
+
+		options = newLoadOptions(nil)
+		assert.Equal(t, defaultLoadTimeout, options.LoadTimeout)
+	})
+}
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Minute, jitter(time.Minute, 0))
+	for range 100 {
+		ttl := jitter(time.Minute, 0.1)
+		assert.GreaterOrEqual(t, ttl, 54*time.Second)
+		assert.LessOrEqual(t, ttl, 66*time.Second)
+	}
+}
+
+func queriesOf(snapshot clientContext.Snapshot) []string {
+	var queries []string
+	for _, call := range snapshot.Database {
+		queries = append(queries, call.Query)
+	}
+	return queries
+}
+
+func actionsOf(snapshot clientContext.Snapshot) []string {
+	var actions []string
+	for _, call := range snapshot.Cache {
+		actions = append(actions, call.Action)
+	}
+	return actions
+}
@@ -0,0 +1,396 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"example/web-service-gin/app/appTracer"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxEntries bounds the memory cache when no size is configured
+const DefaultMaxEntries = 10000
+
+var errNotAnInteger = errors.New("value is not an integer")
+
+// memoryCache is an in-process LRU Cacher bounded by the number of entries.
+// When maxTTL is set every entry expires after it at the latest, which keeps a local copy
+// from drifting too far from a shared cache it fronts.
+type memoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxTTL     time.Duration
+	entries    *list.List
+	items      map[string]*list.Element
+	tags       map[string]map[string]struct{}
+	appTracer  appTracer.AppTracer
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key   string
+	value string
+	// expiresAt is zero for entries that never expire
+	expiresAt time.Time
+	tags      []string
+}
+
+// NewMemoryCacher creates an in-process LRU Cacher holding at most maxEntries.
+// A zero maxTTL lets entries keep the expiration they were set with.
+func NewMemoryCacher(maxEntries int, maxTTL time.Duration, appTracer appTracer.AppTracer) Cacher {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &memoryCache{
+		maxEntries: maxEntries,
+		maxTTL:     maxTTL,
+		entries:    list.New(),
+		items:      map[string]*list.Element{},
+		tags:       map[string]map[string]struct{}{},
+		appTracer:  appTracer,
+		now:        time.Now,
+	}
+}
+
+func (mc *memoryCache) Get(serviceName string, ctx context.Context, key string) (string, error) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	entry, found := mc.get(key)
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "get", key, startTime, nil, found)
+
+	if !found {
+		return "", ErrCacheMiss
+	}
+	return entry.value, nil
+}
+
+func (mc *memoryCache) Set(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) error {
+	return mc.SetWithTags(serviceName, ctx, key, value, expiration)
+}
+
+func (mc *memoryCache) SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	_, found := mc.get(key)
+	if !found {
+		mc.set(key, value, expiration, nil)
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "setnx", key, startTime, nil, false)
+	span.SetAttributes(attribute.Bool("cache.set", !found))
+
+	return !found, nil
+}
+
+func (mc *memoryCache) Delete(serviceName string, ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	for _, key := range keys {
+		mc.remove(key)
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "delete", strings.Join(keys, ","), startTime, nil, false)
+
+	return nil
+}
+
+func (mc *memoryCache) CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	entry, found := mc.get(key)
+	deleted := found && entry.value == value && mc.remove(key)
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "compareanddelete", key, startTime, nil, deleted)
+	span.SetAttributes(attribute.Bool("cache.deleted", deleted))
+
+	return deleted, nil
+}
+
+func (mc *memoryCache) SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	mc.set(key, value, expiration, tags)
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "set", key, startTime, nil, false)
+	if len(tags) > 0 {
+		span.SetAttributes(attribute.StringSlice("cache.tags", tags))
+	}
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	return nil
+}
+
+func (mc *memoryCache) InvalidateTags(serviceName string, ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	deleted := 0
+	mc.mu.Lock()
+	for _, tag := range tags {
+		for key := range mc.tags[tag] {
+			if mc.remove(key) {
+				deleted++
+			}
+		}
+		delete(mc.tags, tag)
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "invalidate", strings.Join(tags, ","), startTime, nil, false)
+	span.SetAttributes(attribute.Int("cache.deletedKeys", deleted))
+
+	return nil
+}
+
+func (mc *memoryCache) MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	for _, key := range keys {
+		if entry, found := mc.get(key); found {
+			values[key] = entry.value
+		}
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "mget", strings.Join(keys, ","), startTime, nil, len(values) > 0)
+	span.SetAttributes(attribute.Int("cache.hits", len(values)))
+
+	return values, nil
+}
+
+func (mc *memoryCache) MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	keys := make([]string, 0, len(values))
+	mc.mu.Lock()
+	for key, value := range values {
+		keys = append(keys, key)
+		mc.set(key, value, expiration, nil)
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "mset", strings.Join(keys, ","), startTime, nil, false)
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	return nil
+}
+
+func (mc *memoryCache) TTL(serviceName string, ctx context.Context, key string) (time.Duration, error) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	entry, found := mc.get(key)
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "ttl", key, startTime, nil, found)
+
+	switch {
+	case !found:
+		return 0, ErrCacheMiss
+	case entry.expiresAt.IsZero():
+		return NoExpiration, nil
+	default:
+		return entry.expiresAt.Sub(mc.now()), nil
+	}
+}
+
+func (mc *memoryCache) Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	entry, found := mc.get(key)
+	if found {
+		entry.expiresAt = mc.expiresAt(expiration)
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "expire", key, startTime, nil, found)
+	span.SetAttributes(attribute.Int("cache.expirationSeconds", int(expiration.Seconds())))
+
+	if !found {
+		return ErrCacheMiss
+	}
+	return nil
+}
+
+func (mc *memoryCache) Exists(serviceName string, ctx context.Context, key string) (bool, error) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	_, found := mc.get(key)
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "exists", key, startTime, nil, found)
+
+	return found, nil
+}
+
+func (mc *memoryCache) Incr(serviceName string, ctx context.Context, key string) (int64, error) {
+	return mc.incrBy(serviceName, ctx, "incr", key, 1)
+}
+
+func (mc *memoryCache) Decr(serviceName string, ctx context.Context, key string) (int64, error) {
+	return mc.incrBy(serviceName, ctx, "decr", key, -1)
+}
+
+// incrBy keeps the expiration of an existing counter, like redis does
+func (mc *memoryCache) incrBy(serviceName string, ctx context.Context, action string, key string, delta int64) (int64, error) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	mc.mu.Lock()
+	var err error
+	var value int64
+	entry, found := mc.get(key)
+	if !found {
+		value = delta
+		mc.set(key, strconv.FormatInt(value, 10), 0, nil)
+	} else if current, parseErr := strconv.ParseInt(entry.value, 10, 64); parseErr != nil {
+		err = errNotAnInteger
+	} else {
+		value = current + delta
+		entry.value = strconv.FormatInt(value, 10)
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, action, key, startTime, err, found)
+
+	if err != nil {
+		return 0, MapCacheError(&err)
+	}
+	return value, nil
+}
+
+// prefixDeleter is implemented by the caches that can evict every key starting with a prefix
+type prefixDeleter interface {
+	deletePrefixes(serviceName string, ctx context.Context, prefixes ...string)
+}
+
+func (mc *memoryCache) deletePrefixes(serviceName string, ctx context.Context, prefixes ...string) {
+	startTime := time.Now()
+	ctx, span := mc.appTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	invalidation := Invalidation{Prefixes: prefixes}
+	deleted := 0
+	mc.mu.Lock()
+	for key := range mc.items {
+		if invalidation.Matches(key) && mc.remove(key) {
+			deleted++
+		}
+	}
+	mc.mu.Unlock()
+	mc.record(ctx, span, serviceName, "deletePrefix", strings.Join(prefixes, ","), startTime, nil, false)
+	span.SetAttributes(attribute.Int("cache.deletedKeys", deleted))
+}
+
+func (mc *memoryCache) record(ctx context.Context, span trace.Span, serviceName string, action string, key string, startTime time.Time, err error, hit bool) {
+	recordCacheCall(ctx, span, "memory", serviceName, action, key, startTime, err, hit)
+}
+
+// get returns the live entry of the key and marks it as recently used. Expired entries are removed.
+// The caller must hold the lock
+func (mc *memoryCache) get(key string) (*memoryEntry, bool) {
+	element, found := mc.items[key]
+	if !found {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !mc.now().Before(entry.expiresAt) {
+		mc.remove(key)
+		return nil, false
+	}
+	mc.entries.MoveToFront(element)
+	return entry, true
+}
+
+// set stores the entry and evicts the least recently used ones over the size limit.
+// The caller must hold the lock
+func (mc *memoryCache) set(key string, value string, expiration time.Duration, tags []string) {
+	mc.remove(key)
+
+	entry := &memoryEntry{key: key, value: value, expiresAt: mc.expiresAt(expiration), tags: tags}
+	mc.items[key] = mc.entries.PushFront(entry)
+	for _, tag := range tags {
+		if mc.tags[tag] == nil {
+			mc.tags[tag] = map[string]struct{}{}
+		}
+		mc.tags[tag][key] = struct{}{}
+	}
+
+	for mc.entries.Len() > mc.maxEntries {
+		oldest := mc.entries.Back().Value.(*memoryEntry)
+		mc.remove(oldest.key)
+	}
+}
+
+// remove deletes the key and untracks it from its tags, reporting whether it was present.
+// The caller must hold the lock
+func (mc *memoryCache) remove(key string) bool {
+	element, found := mc.items[key]
+	if !found {
+		return false
+	}
+	entry := element.Value.(*memoryEntry)
+	for _, tag := range entry.tags {
+		delete(mc.tags[tag], key)
+		if len(mc.tags[tag]) == 0 {
+			delete(mc.tags, tag)
+		}
+	}
+	mc.entries.Remove(element)
+	delete(mc.items, key)
+	return true
+}
+
+// expiresAt returns when an entry set now with the expiration expires, capped by maxTTL
+func (mc *memoryCache) expiresAt(expiration time.Duration) time.Time {
+	if mc.maxTTL > 0 && (expiration <= 0 || expiration > mc.maxTTL) {
+		expiration = mc.maxTTL
+	}
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return mc.now().Add(expiration)
+}
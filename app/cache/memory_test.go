@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"example/web-service-gin/config"
+	"example/web-service-gin/testUtils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestMemory(maxEntries int, maxTTL time.Duration) (*memoryCache, *time.Time) {
+	now := time.Now()
+	cacher := NewMemoryCacher(maxEntries, maxTTL, testUtils.NewAppTracer()).(*memoryCache)
+	cacher.now = func() time.Time { return now }
+	return cacher, &now
+}
+
+func TestMemoryGetSet(t *testing.T) {
+	cacher, now := setupTestMemory(10, 0)
+	ctx := testUtils.CreateTestContext()
+
+	t.Run("Returns the value that was set", func(t *testing.T) {
+		assert.NoError(t, cacher.Set(serviceName, ctx, "key", "value", time.Minute))
+		value, err := cacher.Get(serviceName, ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	})
+
+	t.Run("Missing key is a miss", func(t *testing.T) {
+		value, err := cacher.Get(serviceName, ctx, "missing")
+		assert.Equal(t, ErrCacheMiss, err)
+		assert.Equal(t, "", value)
+	})
+
+	t.Run("Expired key is a miss", func(t *testing.T) {
+		assert.NoError(t, cacher.Set(serviceName, ctx, "expiring", "value", time.Second))
+		*now = now.Add(time.Second)
+		_, err := cacher.Get(serviceName, ctx, "expiring")
+		assert.Equal(t, ErrCacheMiss, err)
+		assert.NotContains(t, cacher.items, "expiring")
+	})
+
+	t.Run("Key without expiration never expires", func(t *testing.T) {
+		assert.NoError(t, cacher.Set(serviceName, ctx, "forever", "value", 0))
+		*now = now.Add(24 * time.Hour)
+		ttl, err := cacher.TTL(serviceName, ctx, "forever")
+		assert.NoError(t, err)
+		assert.Equal(t, NoExpiration, ttl)
+	})
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	cacher, _ := setupTestMemory(2, 0)
+	ctx := testUtils.CreateTestContext()
+
+	cacher.Set(serviceName, ctx, "first", "1", 0)
+	cacher.Set(serviceName, ctx, "second", "2", 0)
+	// Reading first makes second the least recently used
+	cacher.Get(serviceName, ctx, "first")
+	cacher.Set(serviceName, ctx, "third", "3", 0)
+
+	values, err := cacher.MGet(serviceName, ctx, "first", "second", "third")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"first": "1", "third": "3"}, values)
+}
+
+func TestMemoryMaxTTL(t *testing.T) {
+	cacher, _ := setupTestMemory(10, time.Minute)
+	ctx := testUtils.CreateTestContext()
+
+	cacher.Set(serviceName, ctx, "long", "value", time.Hour)
+	cacher.Set(serviceName, ctx, "forever", "value", 0)
+	cacher.Set(serviceName, ctx, "short", "value", time.Second)
+
+	for key, expected := range map[string]time.Duration{"long": time.Minute, "forever": time.Minute, "short": time.Second} {
+		ttl, err := cacher.TTL(serviceName, ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, ttl, key)
+	}
+}
+
+func TestMemorySetNX(t *testing.T) {
+	cacher, _ := setupTestMemory(10, 0)
+	ctx := testUtils.CreateTestContext()
+
+	set, err := cacher.SetNX(serviceName, ctx, "lock", "1", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, set)
+
+	set, err = cacher.SetNX(serviceName, ctx, "lock", "2", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, set)
+
+	value, _ := cacher.Get(serviceName, ctx, "lock")
+	assert.Equal(t, "1", value)
+}
+
+func TestMemoryCompareAndDelete(t *testing.T) {
+	cacher, _ := setupTestMemory(10, 0)
+	ctx := testUtils.CreateTestContext()
+	cacher.Set(serviceName, ctx, "lock", "owner", time.Minute)
+
+	deleted, err := cacher.CompareAndDelete(serviceName, ctx, "lock", "someone else")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = cacher.CompareAndDelete(serviceName, ctx, "lock", "owner")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	exists, _ := cacher.Exists(serviceName, ctx, "lock")
+	assert.False(t, exists)
+}
+
+func TestMemoryTags(t *testing.T) {
+	cacher, _ := setupTestMemory(10, 0)
+	ctx := testUtils.CreateTestContext()
+
+	cacher.SetWithTags(serviceName, ctx, "page:1", "a", time.Minute, "albums")
+	cacher.SetWithTags(serviceName, ctx, "page:2", "b", time.Minute, "albums", "other")
+	cacher.Set(serviceName, ctx, "untagged", "c", time.Minute)
+
+	assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "albums"))
+
+	values, _ := cacher.MGet(serviceName, ctx, "page:1", "page:2", "untagged")
+	assert.Equal(t, map[string]string{"untagged": "c"}, values)
+	assert.Empty(t, cacher.tags, "Tags should stop tracking deleted keys")
+}
+
+func TestMemoryExpireExistsDelete(t *testing.T) {
+	cacher, _ := setupTestMemory(10, 0)
+	ctx := testUtils.CreateTestContext()
+
+	cacher.MSet(serviceName, ctx, map[string]string{"a": "1", "b": "2"}, 0)
+
+	assert.NoError(t, cacher.Expire(serviceName, ctx, "a", time.Minute))
+	ttl, _ := cacher.TTL(serviceName, ctx, "a")
+	assert.Equal(t, time.Minute, ttl)
+	assert.Equal(t, ErrCacheMiss, cacher.Expire(serviceName, ctx, "missing", time.Minute))
+
+	assert.NoError(t, cacher.Delete(serviceName, ctx, "a", "missing"))
+	exists, err := cacher.Exists(serviceName, ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, _ = cacher.Exists(serviceName, ctx, "b")
+	assert.True(t, exists)
+}
+
+func TestMemoryIncrDecr(t *testing.T) {
+	cacher, _ := setupTestMemory(10, 0)
+	ctx := testUtils.CreateTestContext()
+
+	value, err := cacher.Incr(serviceName, ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	value, _ = cacher.Incr(serviceName, ctx, "counter")
+	assert.Equal(t, int64(2), value)
+
+	value, _ = cacher.Decr(serviceName, ctx, "counter")
+	assert.Equal(t, int64(1), value)
+
+	cacher.Set(serviceName, ctx, "text", "abc", 0)
+	_, err = cacher.Incr(serviceName, ctx, "text")
+	assert.Equal(t, ErrCacheGeneric, err)
+}
+
+func TestNewCacherFromConfig(t *testing.T) {
+	tracer := testUtils.NewAppTracer()
+
+	cacher, err := NewCacherFromConfig(config.ConfigFile{Cache: config.CacheConfig{Driver: DriverMemory}}, tracer)
+	assert.NoError(t, err)
+	assert.IsType(t, &memoryCache{}, cacher)
+
+	cacher, err = NewCacherFromConfig(config.ConfigFile{Cache: config.CacheConfig{Driver: DriverTiered, LocalTTL: time.Second}}, tracer)
+	assert.NoError(t, err)
+	assert.IsType(t, &tieredCache{}, cacher)
+
+	cacher, err = NewCacherFromConfig(config.ConfigFile{}, tracer)
+	assert.NoError(t, err)
+	assert.IsType(t, &breakerCache{}, cacher)
+
+	_, err = NewCacherFromConfig(config.ConfigFile{Cache: config.CacheConfig{Driver: "memcached"}}, tracer)
+	assert.Error(t, err)
+}
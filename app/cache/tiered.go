@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tieredCache fronts a shared cache (L2) with an in-process one (L1).
+// Reads are served from L1 when possible and fill it from L2 on a miss. Writes go to L2 first
+// and then to L1, so a failed write never leaves a value only this instance can see.
+// Every write is broadcast on the invalidation bus so the other instances evict their L1 copy.
+// Entries held in L1 also expire after localTTL at the latest, which bounds how long an instance
+// may serve a value another instance has since changed in L2 when a broadcast is lost.
+//
+// Values filled into L1 from L2 arrive without their tags, which only L2 knows. Their keys are
+// tracked instead, and every tag invalidation evicts all of them from L1 along with the tagged ones.
+type tieredCache struct {
+	local    Cacher
+	remote   Cacher
+	localTTL time.Duration
+	bus      InvalidationBus
+	origin   string
+
+	filledMu sync.Mutex
+	filled   map[string]struct{}
+	// overflowed is set when more than maxFilledKeys were filled, and a tag invalidation then evicts all of L1
+	overflowed bool
+}
+
+const (
+	// tieredNamespace is the invalidation namespace tiered caches evict their L1 on
+	tieredNamespace = "cache:local"
+	// maxFilledKeys bounds the filled keys tracked between two tag invalidations
+	maxFilledKeys = 10_000
+)
+
+// NewTieredCacher creates a Cacher serving reads from local before remote.
+// Values are copied into local for at most localTTL. When bus is not nil, writes are
+// broadcast on it and invalidations received from other instances evict local entries.
+func NewTieredCacher(local Cacher, remote Cacher, localTTL time.Duration, bus InvalidationBus) Cacher {
+	tc := &tieredCache{
+		local:    local,
+		remote:   remote,
+		localTTL: localTTL,
+		bus:      bus,
+		origin:   NewOrigin(),
+		filled:   map[string]struct{}{},
+	}
+	if bus != nil {
+		bus.Subscribe(tieredNamespace, tc.evictLocal)
+	}
+	return tc
+}
+
+// Status reports the breaker in front of L2, the only tier that can fail. Without a breaker
+// every call reaches L2, as when the breaker is closed.
+func (tc *tieredCache) Status() BreakerStatus {
+	if reporter, ok := tc.remote.(StatusReporter); ok {
+		return reporter.Status()
+	}
+	return BreakerStatus{State: BreakerClosed}
+}
+
+func (tc *tieredCache) Get(serviceName string, ctx context.Context, key string) (string, error) {
+	if val, err := tc.local.Get(serviceName, ctx, key); err == nil {
+		return val, nil
+	}
+
+	val, err := tc.remote.Get(serviceName, ctx, key)
+	if err != nil {
+		return "", err
+	}
+	tc.fill(serviceName, ctx, map[string]string{key: val})
+	return val, nil
+}
+
+func (tc *tieredCache) Set(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) error {
+	if err := tc.remote.Set(serviceName, ctx, key, value, expiration); err != nil {
+		tc.local.Delete(serviceName, ctx, key)
+		return err
+	}
+	tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	return tc.local.Set(serviceName, ctx, key, value, tc.localExpiration(expiration))
+}
+
+// SetNX only goes to L2. It is used for locks, which must be seen by every instance
+func (tc *tieredCache) SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	tc.local.Delete(serviceName, ctx, key)
+	set, err := tc.remote.SetNX(serviceName, ctx, key, value, expiration)
+	if set {
+		tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	}
+	return set, err
+}
+
+func (tc *tieredCache) Delete(serviceName string, ctx context.Context, keys ...string) error {
+	tc.local.Delete(serviceName, ctx, keys...)
+	if err := tc.remote.Delete(serviceName, ctx, keys...); err != nil {
+		return err
+	}
+	tc.publish(serviceName, ctx, Invalidation{Keys: keys})
+	return nil
+}
+
+// CompareAndDelete only goes to L2, like SetNX which takes the locks it releases
+func (tc *tieredCache) CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error) {
+	tc.local.Delete(serviceName, ctx, key)
+	deleted, err := tc.remote.CompareAndDelete(serviceName, ctx, key, value)
+	if deleted {
+		tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	}
+	return deleted, err
+}
+
+func (tc *tieredCache) SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error {
+	if err := tc.remote.SetWithTags(serviceName, ctx, key, value, expiration, tags...); err != nil {
+		tc.local.Delete(serviceName, ctx, key)
+		return err
+	}
+	tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	return tc.local.SetWithTags(serviceName, ctx, key, value, tc.localExpiration(expiration), tags...)
+}
+
+func (tc *tieredCache) InvalidateTags(serviceName string, ctx context.Context, tags ...string) error {
+	tc.local.InvalidateTags(serviceName, ctx, tags...)
+	tc.evictFilled(serviceName, ctx)
+	if err := tc.remote.InvalidateTags(serviceName, ctx, tags...); err != nil {
+		return err
+	}
+	tc.publish(serviceName, ctx, Invalidation{Tags: tags})
+	return nil
+}
+
+// MGet reads L1 first and only asks L2 for the keys L1 is missing
+func (tc *tieredCache) MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error) {
+	values, _ := tc.local.MGet(serviceName, ctx, keys...)
+	if values == nil {
+		values = make(map[string]string, len(keys))
+	}
+
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, found := values[key]; !found {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	remoteValues, err := tc.remote.MGet(serviceName, ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	if len(remoteValues) > 0 {
+		tc.fill(serviceName, ctx, remoteValues)
+	}
+	for key, value := range remoteValues {
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (tc *tieredCache) MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	if err := tc.remote.MSet(serviceName, ctx, values, expiration); err != nil {
+		tc.local.Delete(serviceName, ctx, keys...)
+		return err
+	}
+	tc.publish(serviceName, ctx, Invalidation{Keys: keys})
+	return tc.local.MSet(serviceName, ctx, values, tc.localExpiration(expiration))
+}
+
+// TTL reports the expiration held by L2, which is the one every instance shares
+func (tc *tieredCache) TTL(serviceName string, ctx context.Context, key string) (time.Duration, error) {
+	return tc.remote.TTL(serviceName, ctx, key)
+}
+
+func (tc *tieredCache) Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error {
+	tc.local.Delete(serviceName, ctx, key)
+	if err := tc.remote.Expire(serviceName, ctx, key, expiration); err != nil {
+		return err
+	}
+	tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	return nil
+}
+
+func (tc *tieredCache) Exists(serviceName string, ctx context.Context, key string) (bool, error) {
+	if found, err := tc.local.Exists(serviceName, ctx, key); err == nil && found {
+		return true, nil
+	}
+	return tc.remote.Exists(serviceName, ctx, key)
+}
+
+// Incr only goes to L2 so the counter is shared between instances
+func (tc *tieredCache) Incr(serviceName string, ctx context.Context, key string) (int64, error) {
+	tc.local.Delete(serviceName, ctx, key)
+	value, err := tc.remote.Incr(serviceName, ctx, key)
+	if err == nil {
+		tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	}
+	return value, err
+}
+
+// Decr only goes to L2 so the counter is shared between instances
+func (tc *tieredCache) Decr(serviceName string, ctx context.Context, key string) (int64, error) {
+	tc.local.Delete(serviceName, ctx, key)
+	value, err := tc.remote.Decr(serviceName, ctx, key)
+	if err == nil {
+		tc.publish(serviceName, ctx, Invalidation{Keys: []string{key}})
+	}
+	return value, err
+}
+
+// localExpiration caps the expiration of a value copied into L1
+func (tc *tieredCache) localExpiration(expiration time.Duration) time.Duration {
+	if tc.localTTL > 0 && (expiration <= 0 || expiration > tc.localTTL) {
+		return tc.localTTL
+	}
+	return expiration
+}
+
+// publish tells the other instances to evict what this instance just changed. A lost broadcast
+// only delays the eviction until localTTL, so publishing errors are not returned.
+func (tc *tieredCache) publish(serviceName string, ctx context.Context, invalidation Invalidation) {
+	if tc.bus == nil {
+		return
+	}
+	invalidation.Namespace = tieredNamespace
+	invalidation.Origin = tc.origin
+	tc.bus.Publish(serviceName, ctx, invalidation)
+}
+
+// evictLocal removes the entries another instance changed from L1
+func (tc *tieredCache) evictLocal(ctx context.Context, invalidation Invalidation) {
+	if invalidation.Origin == tc.origin {
+		return
+	}
+	if len(invalidation.Keys) > 0 {
+		tc.local.Delete(invalidationServiceName, ctx, invalidation.Keys...)
+	}
+	if len(invalidation.Tags) > 0 {
+		tc.local.InvalidateTags(invalidationServiceName, ctx, invalidation.Tags...)
+		tc.evictFilled(invalidationServiceName, ctx)
+	}
+	if deleter, ok := tc.local.(prefixDeleter); ok && len(invalidation.Prefixes) > 0 {
+		deleter.deletePrefixes(invalidationServiceName, ctx, invalidation.Prefixes...)
+	}
+}
+
+// fill copies values read from L2 into L1 and tracks their keys, as their tags are unknown.
+// Keys are tracked after the write, so a fill racing a tag invalidation is still evicted by the next one.
+func (tc *tieredCache) fill(serviceName string, ctx context.Context, values map[string]string) {
+	tc.local.MSet(serviceName, ctx, values, tc.localTTL)
+
+	tc.filledMu.Lock()
+	for key := range values {
+		if len(tc.filled) >= maxFilledKeys {
+			tc.overflowed = true
+			break
+		}
+		tc.filled[key] = struct{}{}
+	}
+	tc.filledMu.Unlock()
+}
+
+// evictFilled removes the values filled from L2 from L1, since any of them may hold an invalidated tag
+func (tc *tieredCache) evictFilled(serviceName string, ctx context.Context) {
+	tc.filledMu.Lock()
+	keys := make([]string, 0, len(tc.filled))
+	for key := range tc.filled {
+		keys = append(keys, key)
+	}
+	overflowed := tc.overflowed
+	tc.filled = map[string]struct{}{}
+	tc.overflowed = false
+	tc.filledMu.Unlock()
+
+	if deleter, ok := tc.local.(prefixDeleter); ok && overflowed {
+		deleter.deletePrefixes(serviceName, ctx, "")
+		return
+	}
+	if len(keys) > 0 {
+		tc.local.Delete(serviceName, ctx, keys...)
+	}
+}
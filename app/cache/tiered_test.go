@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"example/web-service-gin/testUtils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredCache(t *testing.T) {
+	mr, remote := setupTestRedis(t)
+	defer mr.Close()
+
+	local, _ := setupTestMemory(10, time.Minute)
+	cacher := NewTieredCacher(local, remote, time.Minute, nil)
+	ctx := testUtils.CreateTestContext()
+
+	t.Run("Remote hit fills the local cache", func(t *testing.T) {
+		mr.Set("remote-only", "value")
+
+		value, err := cacher.Get(serviceName, ctx, "remote-only")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+
+		mr.Del("remote-only")
+		value, err = cacher.Get(serviceName, ctx, "remote-only")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value, "The second read should be served locally")
+	})
+
+	t.Run("Set writes both tiers and caps the local expiration", func(t *testing.T) {
+		assert.NoError(t, cacher.Set(serviceName, ctx, "both", "value", time.Hour))
+
+		assert.Equal(t, time.Hour, mr.TTL("both"))
+		ttl, err := local.TTL(serviceName, ctx, "both")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, ttl)
+	})
+
+	t.Run("Delete removes the key from both tiers", func(t *testing.T) {
+		cacher.Set(serviceName, ctx, "deleted", "value", time.Minute)
+		assert.NoError(t, cacher.Delete(serviceName, ctx, "deleted"))
+
+		assert.False(t, mr.Exists("deleted"))
+		_, err := cacher.Get(serviceName, ctx, "deleted")
+		assert.Equal(t, ErrCacheMiss, err)
+	})
+
+	t.Run("InvalidateTags purges both tiers", func(t *testing.T) {
+		cacher.SetWithTags(serviceName, ctx, "tagged", "value", time.Minute, "tiered")
+		assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "tiered"))
+
+		assert.False(t, mr.Exists("tagged"))
+		_, err := local.Get(serviceName, ctx, "tagged")
+		assert.Equal(t, ErrCacheMiss, err)
+	})
+
+	t.Run("InvalidateTags purges values filled from the remote", func(t *testing.T) {
+		remote.SetWithTags(serviceName, ctx, "filled:get", "old", time.Minute, "filled")
+		remote.SetWithTags(serviceName, ctx, "filled:mget", "old", time.Minute, "filled")
+		cacher.Get(serviceName, ctx, "filled:get")
+		cacher.MGet(serviceName, ctx, "filled:mget")
+
+		assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "filled"))
+
+		values, _ := local.MGet(serviceName, ctx, "filled:get", "filled:mget")
+		assert.Empty(t, values)
+		_, err := cacher.Get(serviceName, ctx, "filled:get")
+		assert.Equal(t, ErrCacheMiss, err)
+	})
+
+	t.Run("InvalidateTags purges the whole local cache once too many values were filled", func(t *testing.T) {
+		local.Set(serviceName, ctx, "untracked", "value", time.Minute)
+		cacher.(*tieredCache).overflowed = true
+
+		assert.NoError(t, cacher.InvalidateTags(serviceName, ctx, "any"))
+
+		_, err := local.Get(serviceName, ctx, "untracked")
+		assert.Equal(t, ErrCacheMiss, err)
+	})
+
+	t.Run("MGet only asks the remote for local misses", func(t *testing.T) {
+		local.Set(serviceName, ctx, "mget:local", "local", time.Minute)
+		mr.Set("mget:remote", "remote")
+
+		values, err := cacher.MGet(serviceName, ctx, "mget:local", "mget:remote", "mget:missing")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"mget:local": "local", "mget:remote": "remote"}, values)
+
+		value, err := local.Get(serviceName, ctx, "mget:remote")
+		assert.NoError(t, err)
+		assert.Equal(t, "remote", value)
+	})
+
+	t.Run("Counters and locks are shared through the remote", func(t *testing.T) {
+		value, err := cacher.Incr(serviceName, ctx, "counter")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), value)
+		mr.Set("counter", "10")
+		value, _ = cacher.Incr(serviceName, ctx, "counter")
+		assert.Equal(t, int64(11), value)
+
+		mr.Set("held", "1")
+		set, err := cacher.SetNX(serviceName, ctx, "held", "2", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, set)
+	})
+
+	t.Run("Remote errors are returned", func(t *testing.T) {
+		mr.Close()
+		defer mr.Restart()
+
+		_, err := cacher.Get(serviceName, ctx, "unreachable")
+		assert.Equal(t, ErrCacheGeneric, err)
+		assert.Equal(t, ErrCacheGeneric, cacher.Set(serviceName, ctx, "unreachable", "value", time.Minute))
+		_, err = local.Get(serviceName, ctx, "unreachable")
+		assert.Equal(t, ErrCacheMiss, err, "A failed remote write must not be kept locally")
+	})
+}
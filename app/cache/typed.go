@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetJSON reads a JSON encoded value. See GetWithCodec.
+func GetJSON[T any](cacher Cacher, serviceName string, ctx context.Context, key string) (*T, error) {
+	return GetWithCodec[T](cacher, JSONCodec, serviceName, ctx, key)
+}
+
+// SetJSON stores the value as JSON. See SetWithCodec.
+func SetJSON[T any](cacher Cacher, serviceName string, ctx context.Context, key string, value T, expiration time.Duration, tags ...string) error {
+	return SetWithCodec(cacher, JSONCodec, serviceName, ctx, key, value, expiration, tags...)
+}
+
+// GetWithCodec reads the key and decodes it into a T.
+//
+// An entry that cannot be decoded, for example one written before T changed shape, is treated
+// as a miss: it is deleted so the next write replaces it, the failure is recorded on the current
+// span and ErrCacheMiss is returned. Callers only ever need to handle hits and misses.
+func GetWithCodec[T any](cacher Cacher, codec Codec, serviceName string, ctx context.Context, key string) (*T, error) {
+	cached, err := cacher.Get(serviceName, ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if cached == "" {
+		return nil, ErrCacheMiss
+	}
+
+	var value T
+	if decodeErr := codec.Unmarshal([]byte(cached), &value); decodeErr != nil {
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(fmt.Errorf("cannot decode cache entry %s: %w", key, decodeErr), trace.WithAttributes(
+			attribute.String("cache.key", key),
+			attribute.String("cache.codec", codec.Name()),
+		))
+		cacher.Delete(serviceName, ctx, key)
+		return nil, ErrCacheMiss
+	}
+	return &value, nil
+}
+
+// SetWithCodec encodes the value and stores it, recording the key under the tags when any are given.
+func SetWithCodec[T any](cacher Cacher, codec Codec, serviceName string, ctx context.Context, key string, value T, expiration time.Duration, tags ...string) error {
+	encoded, err := codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cannot encode cache entry %s with %s: %w", key, codec.Name(), err)
+	}
+	if len(tags) > 0 {
+		return cacher.SetWithTags(serviceName, ctx, key, string(encoded), expiration, tags...)
+	}
+	return cacher.Set(serviceName, ctx, key, string(encoded), expiration)
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"example/web-service-gin/testUtils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedValue struct {
+	Name  string
+	Count int
+}
+
+func TestTypedHelpers(t *testing.T) {
+	mr, cacher := setupTestRedis(t)
+	defer mr.Close()
+
+	ctx := testUtils.CreateTestContext()
+	value := typedValue{Name: "albums", Count: 3}
+
+	t.Run("JSON round trip", func(t *testing.T) {
+		err := SetJSON(cacher, serviceName, ctx, "json", value, time.Minute)
+		assert.NoError(t, err)
+
+		stored, _ := mr.Get("json")
+		assert.JSONEq(t, `{"Name":"albums","Count":3}`, stored)
+
+		result, err := GetJSON[typedValue](cacher, serviceName, ctx, "json")
+		assert.NoError(t, err)
+		assert.Equal(t, &value, result)
+	})
+
+	t.Run("Gob round trip", func(t *testing.T) {
+		err := SetWithCodec(cacher, GobCodec, serviceName, ctx, "gob", value, time.Minute)
+		assert.NoError(t, err)
+
+		result, err := GetWithCodec[typedValue](cacher, GobCodec, serviceName, ctx, "gob")
+		assert.NoError(t, err)
+		assert.Equal(t, &value, result)
+	})
+
+	t.Run("Set with tags", func(t *testing.T) {
+		err := SetJSON(cacher, serviceName, ctx, "tagged", value, time.Minute, "typed")
+		assert.NoError(t, err)
+
+		members, _ := mr.Members(tagKey("typed"))
+		assert.Equal(t, []string{"tagged"}, members)
+	})
+
+	t.Run("Missing entry", func(t *testing.T) {
+		result, err := GetJSON[typedValue](cacher, serviceName, ctx, "missing")
+		assert.Equal(t, ErrCacheMiss, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Undecodable entry is a miss and is deleted", func(t *testing.T) {
+		mr.Set("corrupted", "{not json")
+
+		result, err := GetJSON[typedValue](cacher, serviceName, ctx, "corrupted")
+		assert.Equal(t, ErrCacheMiss, err)
+		assert.Nil(t, result)
+		assert.False(t, mr.Exists("corrupted"))
+	})
+
+	t.Run("Unencodable value", func(t *testing.T) {
+		err := SetJSON(cacher, serviceName, ctx, "func", func() {}, time.Minute)
+		assert.Error(t, err)
+		assert.False(t, mr.Exists("func"))
+	})
+}
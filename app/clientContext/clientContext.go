@@ -2,6 +2,7 @@ package clientContext
 
 import (
 	"context"
+	"sync"
 	"time"
 )
 
@@ -104,49 +105,243 @@ type CacheCall struct {
 	Error error
 }
 
+// MaxCalls is the number of calls of each kind a ClientContext keeps. Calls past it are only counted,
+// so a request looping over thousands of queries doesn't hold them all in memory until it is logged.
+const MaxCalls = 100
+
+// callList is a list of calls bounded by MaxCalls
+type callList[T any] struct {
+	calls   []T
+	dropped int
+}
+
+func (l *callList[T]) add(call T) {
+	if len(l.calls) >= MaxCalls {
+		l.dropped++
+		return
+	}
+	l.calls = append(l.calls, call)
+}
+
+func (l *callList[T]) addAll(calls []T, dropped int) {
+	for _, call := range calls {
+		l.add(call)
+	}
+	l.dropped += dropped
+}
+
+func (l *callList[T]) copy() []T {
+	if len(l.calls) == 0 {
+		return nil
+	}
+	return append([]T(nil), l.calls...)
+}
+
 // ClientContext represents the context information for a client request.
 // It contains information about the service transaction, client, service,
 // request, response, downstream calls, database calls, and cache calls.
+//
+// The exported fields identify the request and are set when the ClientContext is created.
+// Everything recorded while the request runs is guarded by a mutex, so handlers can fan out to
+// goroutines sharing the request context. Read it with the getters, which return copies, or Snapshot.
 type ClientContext struct {
 	ServiceTransaction
-	TraceId      string
-	SpanId       string
-	Client       ClientInfo
-	Request      RequestInfo
-	Response     ResponseInfo
-	Downstreams  []DownstreamCall
-	Database     []DatabaseCall
-	Cache        []CacheCall
-	ResponseTime time.Duration
+	TraceId string
+	SpanId  string
+	Client  ClientInfo
+	Request RequestInfo
+
+	mu           sync.Mutex
+	response     ResponseInfo
+	responseTime time.Duration
+	downstreams  callList[DownstreamCall]
+	database     callList[DatabaseCall]
+	cache        callList[CacheCall]
+}
+
+// Snapshot is a copy of a ClientContext at one point in time, safe to read and log while the request goes on.
+// The Dropped counters hold the calls past MaxCalls that are not in the lists.
+type Snapshot struct {
+	ServiceTransaction
+	TraceId            string
+	SpanId             string
+	Client             ClientInfo
+	Request            RequestInfo
+	Response           ResponseInfo
+	Downstreams        []DownstreamCall
+	Database           []DatabaseCall
+	Cache              []CacheCall
+	DroppedDownstreams int `json:",omitempty"`
+	DroppedDatabase    int `json:",omitempty"`
+	DroppedCache       int `json:",omitempty"`
+	ResponseTime       time.Duration
+}
+
+// FromContext returns the ClientContext of the request, and false when the context has none,
+// such as outside of the ClientContext middleware
+func FromContext(ctx context.Context) (*ClientContext, bool) {
+	currentContext, ok := ctx.Value(ClientContextKey).(*ClientContext)
+	return currentContext, ok && currentContext != nil
 }
 
+// GetClientContext returns the ClientContext of the request, or nil when the context has none
 func GetClientContext(ctx context.Context) *ClientContext {
-	return ctx.Value(ClientContextKey).(*ClientContext)
+	currentContext, _ := FromContext(ctx)
+	return currentContext
+}
+
+// Detach returns a context for work that outlives the request, such as a background cache refresh.
+// It is never cancelled with the request and carries a new ClientContext with the same trace, client and
+// request information but no calls, so the background work doesn't modify the request's ClientContext.
+// Work shared with requests hands its calls back to them with AddCalls once it is done.
+func Detach(ctx context.Context) context.Context {
+	detached := context.WithoutCancel(ctx)
+	currentContext, ok := FromContext(ctx)
+	if !ok {
+		return detached
+	}
+	backgroundContext := ClientContext{
+		ServiceTransaction: currentContext.ServiceTransaction,
+		TraceId:            currentContext.TraceId,
+		SpanId:             currentContext.SpanId,
+		Client:             currentContext.Client,
+		Request:            currentContext.Request,
+	}
+	return context.WithValue(detached, ClientContextKey, &backgroundContext)
 }
 
-// since we are saving the client context as a pointer add any modifications to the client context here and handle multiple go routines safely
+// Snapshot copies the ClientContext
+func (c *ClientContext) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Snapshot{
+		ServiceTransaction: c.ServiceTransaction,
+		TraceId:            c.TraceId,
+		SpanId:             c.SpanId,
+		Client:             c.Client,
+		Request:            c.Request,
+		Response:           c.response,
+		Downstreams:        c.downstreams.copy(),
+		Database:           c.database.copy(),
+		Cache:              c.cache.copy(),
+		DroppedDownstreams: c.downstreams.dropped,
+		DroppedDatabase:    c.database.dropped,
+		DroppedCache:       c.cache.dropped,
+		ResponseTime:       c.responseTime,
+	}
+}
+
+// Response returns the response recorded by AddResponseInfo
+func (c *ClientContext) Response() ResponseInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.response
+}
+
+// ResponseTime returns the response time recorded by AddResponseTime
+func (c *ClientContext) ResponseTime() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responseTime
+}
+
+// DownstreamCalls returns a copy of the downstream calls recorded so far
+func (c *ClientContext) DownstreamCalls() []DownstreamCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.downstreams.copy()
+}
+
+// DatabaseCalls returns a copy of the database calls recorded so far
+func (c *ClientContext) DatabaseCalls() []DatabaseCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.database.copy()
+}
+
+// CacheCalls returns a copy of the cache calls recorded so far
+func (c *ClientContext) CacheCalls() []CacheCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.copy()
+}
+
+func (c *ClientContext) setResponseTime(responseTime time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responseTime = responseTime
+}
+
+func (c *ClientContext) setResponse(response ResponseInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.response = response
+}
+
+func (c *ClientContext) addDownstreamCall(call DownstreamCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downstreams.add(call)
+}
+
+func (c *ClientContext) addDatabaseCall(call DatabaseCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.database.add(call)
+}
+
+func (c *ClientContext) addCacheCall(call CacheCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.add(call)
+}
+
+// addCalls records the calls of the snapshot, and those it dropped, after the calls already recorded
+func (c *ClientContext) addCalls(snapshot Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downstreams.addAll(snapshot.Downstreams, snapshot.DroppedDownstreams)
+	c.database.addAll(snapshot.Database, snapshot.DroppedDatabase)
+	c.cache.addAll(snapshot.Cache, snapshot.DroppedCache)
+}
+
+// The functions below record on the ClientContext of the context and are safe to call from any goroutine.
+// They do nothing when the context has no ClientContext.
 
 func AddResponseTime(ctx context.Context, responseTime time.Duration) {
-	currentContext := ctx.Value(ClientContextKey).(*ClientContext)
-	currentContext.ResponseTime = responseTime
+	if currentContext, ok := FromContext(ctx); ok {
+		currentContext.setResponseTime(responseTime)
+	}
 }
 
 func AddResponseInfo(ctx context.Context, response ResponseInfo) {
-	currentContext := ctx.Value(ClientContextKey).(*ClientContext)
-	currentContext.Response = response
+	if currentContext, ok := FromContext(ctx); ok {
+		currentContext.setResponse(response)
+	}
 }
 
 func AddDownstreamCall(ctx context.Context, call DownstreamCall) {
-	currentContext := ctx.Value(ClientContextKey).(*ClientContext)
-	currentContext.Downstreams = append(currentContext.Downstreams, call)
+	if currentContext, ok := FromContext(ctx); ok {
+		currentContext.addDownstreamCall(call)
+	}
 }
 
 func AddDatabaseCall(ctx context.Context, call DatabaseCall) {
-	currentContext := ctx.Value(ClientContextKey).(*ClientContext)
-	currentContext.Database = append(currentContext.Database, call)
+	if currentContext, ok := FromContext(ctx); ok {
+		currentContext.addDatabaseCall(call)
+	}
 }
 
 func AddCacheCall(ctx context.Context, call CacheCall) {
-	currentContext := ctx.Value(ClientContextKey).(*ClientContext)
-	currentContext.Cache = append(currentContext.Cache, call)
+	if currentContext, ok := FromContext(ctx); ok {
+		currentContext.addCacheCall(call)
+	}
+}
+
+// AddCalls records the calls of a snapshot, such as the calls of work shared by several requests
+// and run on a detached context, on the ClientContext of the context
+func AddCalls(ctx context.Context, snapshot Snapshot) {
+	if currentContext, ok := FromContext(ctx); ok {
+		currentContext.addCalls(snapshot)
+	}
 }
@@ -0,0 +1,113 @@
+package clientContext
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContext() (context.Context, *ClientContext) {
+	currentContext := &ClientContext{TraceId: "test-trace-id"}
+	return context.WithValue(context.Background(), ClientContextKey, currentContext), currentContext
+}
+
+func TestFromContext(t *testing.T) {
+	ctx, currentContext := newTestContext()
+
+	found, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Same(t, currentContext, found)
+
+	_, ok = FromContext(context.Background())
+	assert.False(t, ok)
+	assert.Nil(t, GetClientContext(context.Background()))
+}
+
+func TestAddCallsWithoutClientContext(t *testing.T) {
+	assert.NotPanics(t, func() {
+		AddDatabaseCall(context.Background(), DatabaseCall{Query: "SELECT 1"})
+		AddCacheCall(context.Background(), CacheCall{Action: "get"})
+		AddDownstreamCall(context.Background(), DownstreamCall{StatusCode: 200})
+		AddResponseInfo(context.Background(), ResponseInfo{Status: 200})
+		AddResponseTime(context.Background(), 0)
+	})
+}
+
+func TestAddCallsFromGoroutines(t *testing.T) {
+	ctx, currentContext := newTestContext()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				AddDatabaseCall(ctx, DatabaseCall{Query: "SELECT 1"})
+				AddCacheCall(ctx, CacheCall{Action: "get"})
+				AddDownstreamCall(ctx, DownstreamCall{StatusCode: 200})
+				currentContext.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, currentContext.DatabaseCalls(), 50)
+	assert.Len(t, currentContext.CacheCalls(), 50)
+	assert.Len(t, currentContext.DownstreamCalls(), 50)
+}
+
+func TestCallsAreBounded(t *testing.T) {
+	ctx, currentContext := newTestContext()
+
+	for range MaxCalls + 5 {
+		AddDatabaseCall(ctx, DatabaseCall{Query: "SELECT 1"})
+	}
+
+	snapshot := currentContext.Snapshot()
+	assert.Len(t, snapshot.Database, MaxCalls)
+	assert.Equal(t, 5, snapshot.DroppedDatabase)
+	assert.Zero(t, snapshot.DroppedCache)
+}
+
+func TestGettersReturnCopies(t *testing.T) {
+	ctx, currentContext := newTestContext()
+	AddCacheCall(ctx, CacheCall{Key: "albums:1"})
+
+	calls := currentContext.CacheCalls()
+	calls[0].Key = "changed"
+
+	assert.Equal(t, "albums:1", currentContext.CacheCalls()[0].Key)
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx, currentContext := newTestContext()
+	AddResponseInfo(ctx, ResponseInfo{Status: 201})
+	AddDatabaseCall(ctx, DatabaseCall{Query: "INSERT INTO albums"})
+
+	snapshot := currentContext.Snapshot()
+	AddDatabaseCall(ctx, DatabaseCall{Query: "SELECT 1"})
+
+	assert.Equal(t, "test-trace-id", snapshot.TraceId)
+	assert.Equal(t, 201, snapshot.Response.Status)
+	assert.Equal(t, []DatabaseCall{{Query: "INSERT INTO albums"}}, snapshot.Database)
+	assert.Equal(t, 201, currentContext.Response().Status)
+}
+
+func TestAddCalls(t *testing.T) {
+	ctx, currentContext := newTestContext()
+	AddDatabaseCall(ctx, DatabaseCall{Query: "SELECT 1"})
+
+	detached := Detach(ctx)
+	AddDatabaseCall(detached, DatabaseCall{Query: "SELECT id FROM albums"})
+	AddCacheCall(detached, CacheCall{Action: "set"})
+	assert.Len(t, currentContext.DatabaseCalls(), 1)
+
+	AddCalls(ctx, GetClientContext(detached).Snapshot())
+
+	snapshot := currentContext.Snapshot()
+	assert.Equal(t, []DatabaseCall{{Query: "SELECT 1"}, {Query: "SELECT id FROM albums"}}, snapshot.Database)
+	assert.Equal(t, []CacheCall{{Action: "set"}}, snapshot.Cache)
+	assert.Nil(t, snapshot.Downstreams)
+}
@@ -0,0 +1,166 @@
+package clientContext
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Call kinds reported by SlowestCall
+const (
+	DatabaseKind   = "database"
+	CacheKind      = "cache"
+	DownstreamKind = "downstream"
+)
+
+// lookupActions are the cache actions whose Hit tells whether the value was cached
+var lookupActions = map[string]bool{"get": true, "mget": true}
+
+// CallStats sums up the calls of one kind.
+// Count includes the calls dropped past MaxCalls, whose time and errors are unknown.
+type CallStats struct {
+	Count     int
+	Errors    int
+	TotalTime time.Duration
+}
+
+// CacheStats sums up the cache calls. HitRatio is the share of lookups that found their value.
+type CacheStats struct {
+	CallStats
+	Lookups  int
+	Hits     int
+	HitRatio float64
+}
+
+// SlowestCall is the call of the request that took the longest
+type SlowestCall struct {
+	// Kind is DatabaseKind, CacheKind or DownstreamKind
+	Kind string
+	// Name is the query, the cache action and key, or the downstream service
+	Name         string
+	ResponseTime time.Duration
+}
+
+// RepeatedQuery is a query text run Count times during the request
+type RepeatedQuery struct {
+	Query string
+	Count int
+}
+
+// Summary is the cost of a request, computed from its calls
+type Summary struct {
+	Database    CallStats
+	Cache       CacheStats
+	Downstreams CallStats
+	Slowest     *SlowestCall `json:",omitempty"`
+	// RepeatedQueries are the queries run at least the repeated query threshold times, most repeated first.
+	// A query run once per item of a list, the N+1 pattern, shows up here.
+	RepeatedQueries []RepeatedQuery `json:",omitempty"`
+}
+
+// Summary sums up the calls of the snapshot. Queries run at least repeatedQueryThreshold times are
+// reported in RepeatedQueries; a threshold below 2 disables the detection.
+func (s Snapshot) Summary(repeatedQueryThreshold int) Summary {
+	var summary Summary
+	slowest := SlowestCall{}
+
+	summary.Database.Count = len(s.Database) + s.DroppedDatabase
+	queryCounts := map[string]int{}
+	for _, call := range s.Database {
+		summary.Database.add(call.ResponseTime, call.Error)
+		queryCounts[call.Query]++
+		if call.ResponseTime > slowest.ResponseTime {
+			slowest = SlowestCall{Kind: DatabaseKind, Name: call.Query, ResponseTime: call.ResponseTime}
+		}
+	}
+
+	summary.Cache.Count = len(s.Cache) + s.DroppedCache
+	for _, call := range s.Cache {
+		summary.Cache.add(call.ResponseTime, call.Error)
+		if lookupActions[call.Action] {
+			summary.Cache.Lookups++
+			if call.Hit {
+				summary.Cache.Hits++
+			}
+		}
+		if call.ResponseTime > slowest.ResponseTime {
+			slowest = SlowestCall{Kind: CacheKind, Name: call.Action + " " + call.Key, ResponseTime: call.ResponseTime}
+		}
+	}
+	if summary.Cache.Lookups > 0 {
+		summary.Cache.HitRatio = float64(summary.Cache.Hits) / float64(summary.Cache.Lookups)
+	}
+
+	summary.Downstreams.Count = len(s.Downstreams) + s.DroppedDownstreams
+	for _, call := range s.Downstreams {
+		summary.Downstreams.add(call.ResponseTime, call.Error)
+		if call.ResponseTime > slowest.ResponseTime {
+			slowest = SlowestCall{Kind: DownstreamKind, Name: call.ServiceName, ResponseTime: call.ResponseTime}
+		}
+	}
+
+	if slowest.Kind != "" {
+		summary.Slowest = &slowest
+	}
+	if repeatedQueryThreshold >= 2 {
+		summary.RepeatedQueries = repeatedQueries(queryCounts, repeatedQueryThreshold)
+	}
+	return summary
+}
+
+func (c *CallStats) add(responseTime time.Duration, err error) {
+	c.TotalTime += responseTime
+	if err != nil {
+		c.Errors++
+	}
+}
+
+func repeatedQueries(queryCounts map[string]int, threshold int) []RepeatedQuery {
+	var repeated []RepeatedQuery
+	for query, count := range queryCounts {
+		if count >= threshold {
+			repeated = append(repeated, RepeatedQuery{Query: query, Count: count})
+		}
+	}
+	sort.Slice(repeated, func(i, j int) bool {
+		if repeated[i].Count != repeated[j].Count {
+			return repeated[i].Count > repeated[j].Count
+		}
+		return repeated[i].Query < repeated[j].Query
+	})
+	return repeated
+}
+
+// The calls hold their Error as an interface, which encoding/json writes as an empty object.
+// They are written with the error message instead.
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (c DownstreamCall) MarshalJSON() ([]byte, error) {
+	type call DownstreamCall
+	return json.Marshal(struct {
+		call
+		Error string `json:",omitempty"`
+	}{call(c), errorMessage(c.Error)})
+}
+
+func (c DatabaseCall) MarshalJSON() ([]byte, error) {
+	type call DatabaseCall
+	return json.Marshal(struct {
+		call
+		Error string `json:",omitempty"`
+	}{call(c), errorMessage(c.Error)})
+}
+
+func (c CacheCall) MarshalJSON() ([]byte, error) {
+	type call CacheCall
+	return json.Marshal(struct {
+		call
+		Error string `json:",omitempty"`
+	}{call(c), errorMessage(c.Error)})
+}
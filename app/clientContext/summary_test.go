@@ -0,0 +1,87 @@
+package clientContext
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	t.Run("Sums up the calls of every kind", func(t *testing.T) {
+		snapshot := Snapshot{
+			Database: []DatabaseCall{
+				{Query: "SELECT 1", ResponseTime: 2 * time.Millisecond},
+				{Query: "SELECT 2", ResponseTime: 3 * time.Millisecond, Error: errors.New("boom")},
+			},
+			DroppedDatabase: 4,
+			Cache: []CacheCall{
+				{Action: "get", Key: "a", Hit: true, ResponseTime: time.Millisecond},
+				{Action: "get", Key: "b", ResponseTime: time.Millisecond},
+				{Action: "mget", Key: "c,d", Hit: true, ResponseTime: time.Millisecond},
+				{Action: "set", Key: "b", ResponseTime: time.Millisecond},
+			},
+			Downstreams: []DownstreamCall{
+				{ServiceTransaction: ServiceTransaction{ServiceName: "payments:443"}, StatusCode: 200, ResponseTime: 10 * time.Millisecond},
+			},
+		}
+
+		summary := snapshot.Summary(5)
+
+		assert.Equal(t, CallStats{Count: 6, Errors: 1, TotalTime: 5 * time.Millisecond}, summary.Database)
+		assert.Equal(t, CacheStats{
+			CallStats: CallStats{Count: 4, TotalTime: 4 * time.Millisecond},
+			Lookups:   3,
+			Hits:      2,
+			HitRatio:  2.0 / 3.0,
+		}, summary.Cache)
+		assert.Equal(t, CallStats{Count: 1, TotalTime: 10 * time.Millisecond}, summary.Downstreams)
+		assert.Equal(t, &SlowestCall{Kind: DownstreamKind, Name: "payments:443", ResponseTime: 10 * time.Millisecond}, summary.Slowest)
+		assert.Empty(t, summary.RepeatedQueries)
+	})
+
+	t.Run("Has no slowest call without calls", func(t *testing.T) {
+		summary := Snapshot{}.Summary(5)
+
+		assert.Nil(t, summary.Slowest)
+		assert.Zero(t, summary.Cache.HitRatio)
+	})
+
+	t.Run("Reports queries repeated at least the threshold", func(t *testing.T) {
+		var snapshot Snapshot
+		for i := 0; i < 5; i++ {
+			snapshot.Database = append(snapshot.Database, DatabaseCall{Query: "SELECT * FROM tracks WHERE album_id = $1"})
+		}
+		for i := 0; i < 3; i++ {
+			snapshot.Database = append(snapshot.Database, DatabaseCall{Query: "SELECT * FROM artists WHERE id = $1"})
+		}
+		snapshot.Database = append(snapshot.Database, DatabaseCall{Query: "SELECT * FROM albums"})
+
+		assert.Equal(t, []RepeatedQuery{
+			{Query: "SELECT * FROM tracks WHERE album_id = $1", Count: 5},
+		}, snapshot.Summary(5).RepeatedQueries)
+		assert.Equal(t, []RepeatedQuery{
+			{Query: "SELECT * FROM tracks WHERE album_id = $1", Count: 5},
+			{Query: "SELECT * FROM artists WHERE id = $1", Count: 3},
+		}, snapshot.Summary(3).RepeatedQueries)
+		assert.Empty(t, snapshot.Summary(0).RepeatedQueries)
+	})
+}
+
+func TestCallsMarshalErrorMessages(t *testing.T) {
+	call := DatabaseCall{
+		ServiceTransaction: ServiceTransaction{ServiceName: "AlbumRepository"},
+		Query:              "SELECT 1",
+		Error:              errors.New("connection refused"),
+	}
+
+	data, err := json.Marshal(call)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"ServiceName":"AlbumRepository","SpanId":"","Query":"SELECT 1","ResponseTime":0,"Error":"connection refused"}`, string(data))
+
+	data, err = json.Marshal(CacheCall{Action: "get"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "Error")
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var errCopyOutsideTx = errors.New("CopyIn must run in a transaction started by WithTx")
+
+// CopyIn loads rows into the columns of the table with the COPY protocol and returns how many were copied
+//
+// COPY streams the rows instead of sending them as statement arguments, so it has no parameter limit
+// and is the fastest way to load large batches. It can't upsert: copy into a temporary table and
+// INSERT ... SELECT from it to resolve conflicts. COPY only runs in a transaction, so the context must
+// come from WithTx. The copy is traced and recorded in the client context as a single call.
+//
+// Parameters:
+//   - serviceName: The name of the service making the database call. Used for tracing.
+//   - ctx: The context of a transaction started by WithTx.
+//   - table: The table to copy into.
+//   - columns: The columns the values of every row are copied into.
+//   - rowCount: The number of rows to copy.
+//   - row: Returns the values of the i-th row, in the order of columns. Rows are read one at a time,
+//     so a large import doesn't have to hold the arguments of every row at once.
+//
+// Example usage:
+//
+//	err := db.WithTx(ctx, nil, func(ctx context.Context) error {
+//	    _, err := db.CopyIn("UserService", ctx, "users", []string{"id", "name"}, len(users), func(i int) []any {
+//	        return []any{users[i].ID, users[i].Name}
+//	    })
+//	    return err
+//	})
+
+func (db *DatabaseImpl) CopyIn(serviceName string, ctx context.Context, table string, columns []string, rowCount int, row func(i int) []any) (int64, error) {
+	tx := transactionFromContext(ctx)
+	if tx == nil {
+		return 0, errCopyOutsideTx
+	}
+
+	startTime := time.Now()
+	spanCtx, span := db.AppTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	copied, err := copyRows(spanCtx, tx.tx, table, columns, rowCount, row)
+	if err != nil {
+		markRetryable(ctx, err)
+	}
+	db.recordCall(ctx, span, serviceName, fmt.Sprintf("COPY %s (%s) FROM STDIN", table, strings.Join(columns, ", ")), startTime, err)
+	return copied, err
+}
+
+func copyRows(ctx context.Context, tx *sql.Tx, table string, columns []string, rowCount int, row func(i int) []any) (int64, error) {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for i := range rowCount {
+		if _, err := stmt.ExecContext(ctx, row(i)...); err != nil {
+			return 0, err
+		}
+	}
+	// Executing the statement without arguments flushes the buffered rows and ends the copy
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
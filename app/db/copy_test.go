@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyIn(t *testing.T) {
+	rows := [][]any{{"1", "Blue Train"}, {"2", "Kind of Blue"}}
+	row := func(i int) []any { return rows[i] }
+
+	t.Run("Streams the rows in the transaction", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		copyIn := mock.ExpectPrepare(`COPY "albums" \("id", "title"\) FROM STDIN`)
+		copyIn.ExpectExec().WithArgs("1", "Blue Train").WillReturnResult(sqlmock.NewResult(0, 0))
+		copyIn.ExpectExec().WithArgs("2", "Kind of Blue").WillReturnResult(sqlmock.NewResult(0, 0))
+		copyIn.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		var copied int64
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			var err error
+			copied, err = database.CopyIn("test", ctx, "albums", []string{"id", "title"}, len(rows), row)
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), copied)
+		assert.Contains(t, recordedQueries(ctx), "COPY albums (id, title) FROM STDIN")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Needs a transaction", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+
+		_, err := database.CopyIn("test", ctx, "albums", []string{"id", "title"}, len(rows), row)
+
+		assert.Equal(t, errCopyOutsideTx, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
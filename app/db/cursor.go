@@ -0,0 +1,140 @@
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// CursorDirection tells a keyset query which side of the cursor key to seek to.
+type CursorDirection string
+
+const (
+	CursorNext CursorDirection = "next"
+	CursorPrev CursorDirection = "prev"
+)
+
+// Cursor is the decoded form of the opaque cursor token handed to clients.
+// Key holds the sort key of the row the page starts after (next) or before (prev).
+type Cursor[K any] struct {
+	Key       K               `json:"k"`
+	Direction CursorDirection `json:"d"`
+}
+
+// cursorSecret signs cursor tokens so clients cannot forge keys.
+// It defaults to a random per process secret; set a shared one with SetCursorSecret
+// so cursors stay valid across instances and restarts.
+var cursorSecret = randomCursorSecret()
+
+func randomCursorSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+// SetCursorSecret sets the key used to sign cursor tokens. An empty secret keeps the current one.
+func SetCursorSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	cursorSecret = []byte(secret)
+}
+
+func signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cursorSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// EncodeCursor serializes and signs a cursor into an opaque, URL safe token.
+func EncodeCursor[K any](cursor Cursor[K]) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(signCursor(payload)), nil
+}
+
+// DecodeCursor verifies the signature of a token produced by EncodeCursor and returns the cursor.
+// Any malformed, tampered or unknown token returns InvalidCursorError.
+func DecodeCursor[K any](token string) (*Cursor[K], error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, InvalidCursorError
+	}
+	encoding := base64.RawURLEncoding
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, InvalidCursorError
+	}
+	signature, err := encoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, signCursor(payload)) {
+		return nil, InvalidCursorError
+	}
+
+	var cursor Cursor[K]
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, InvalidCursorError
+	}
+	if cursor.Direction != CursorNext && cursor.Direction != CursorPrev {
+		return nil, InvalidCursorError
+	}
+	return &cursor, nil
+}
+
+// NewKeysetPaginated builds a page from the rows of a keyset query.
+//
+// The query is expected to:
+//   - seek past cursor.Key in the cursor direction (or start at the beginning when cursor is nil)
+//   - order descending when the direction is CursorPrev
+//   - fetch limit+1 rows so the extra row tells us whether another page exists
+//
+// keyFn extracts the stable sort key from a row and is used to build nextCursor and prevCursor.
+func NewKeysetPaginated[T any, K any](rows []T, limit int, cursor *Cursor[K], keyFn func(T) K) (*Paginated[T], error) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	backwards := cursor != nil && cursor.Direction == CursorPrev
+	if backwards {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	hasNext := hasMore
+	hasPrev := cursor != nil
+	if backwards {
+		hasNext = true
+		hasPrev = hasMore
+	}
+
+	page := &Paginated[T]{
+		Items:   rows,
+		Limit:   limit,
+		HasNext: hasNext && len(rows) > 0,
+	}
+
+	if page.HasNext {
+		next, err := EncodeCursor(Cursor[K]{Key: keyFn(rows[len(rows)-1]), Direction: CursorNext})
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	if hasPrev && len(rows) > 0 {
+		prev, err := EncodeCursor(Cursor[K]{Key: keyFn(rows[0]), Direction: CursorPrev})
+		if err != nil {
+			return nil, err
+		}
+		page.PrevCursor = prev
+	}
+	return page, nil
+}
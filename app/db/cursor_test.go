@@ -0,0 +1,92 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorEncoding(t *testing.T) {
+
+	t.Run("Round trip", func(t *testing.T) {
+		token, err := EncodeCursor(Cursor[string]{Key: "42", Direction: CursorNext})
+		assert.NoError(t, err)
+
+		cursor, err := DecodeCursor[string](token)
+		assert.NoError(t, err)
+		assert.Equal(t, &Cursor[string]{Key: "42", Direction: CursorNext}, cursor)
+	})
+
+	t.Run("Tampered payload", func(t *testing.T) {
+		token, _ := EncodeCursor(Cursor[string]{Key: "42", Direction: CursorNext})
+		forged, _ := EncodeCursor(Cursor[string]{Key: "99", Direction: CursorNext})
+
+		_, signature, _ := strings.Cut(token, ".")
+		forgedPayload, _, _ := strings.Cut(forged, ".")
+		_, err := DecodeCursor[string](forgedPayload + "." + signature)
+		assert.Equal(t, InvalidCursorError, err)
+	})
+
+	t.Run("Signed with another secret", func(t *testing.T) {
+		previous := cursorSecret
+		defer func() { cursorSecret = previous }()
+
+		token, _ := EncodeCursor(Cursor[string]{Key: "42", Direction: CursorNext})
+		SetCursorSecret("another-secret")
+
+		_, err := DecodeCursor[string](token)
+		assert.Equal(t, InvalidCursorError, err)
+	})
+
+	t.Run("Malformed tokens", func(t *testing.T) {
+		for _, token := range []string{"", "abc", "abc.def", "!!!.???"} {
+			_, err := DecodeCursor[string](token)
+			assert.Equal(t, InvalidCursorError, err, token)
+		}
+	})
+}
+
+func TestNewKeysetPaginated(t *testing.T) {
+	identity := func(i int) int { return i }
+
+	t.Run("First page with more rows", func(t *testing.T) {
+		page, err := NewKeysetPaginated([]int{1, 2, 3}, 2, nil, identity)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, page.Items)
+		assert.True(t, page.HasNext)
+		assert.Empty(t, page.PrevCursor)
+
+		next, err := DecodeCursor[int](page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, &Cursor[int]{Key: 2, Direction: CursorNext}, next)
+	})
+
+	t.Run("Last page going forward", func(t *testing.T) {
+		page, err := NewKeysetPaginated([]int{5}, 2, &Cursor[int]{Key: 4, Direction: CursorNext}, identity)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{5}, page.Items)
+		assert.False(t, page.HasNext)
+		assert.Empty(t, page.NextCursor)
+
+		prev, err := DecodeCursor[int](page.PrevCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, &Cursor[int]{Key: 5, Direction: CursorPrev}, prev)
+	})
+
+	t.Run("Going backwards reverses the rows", func(t *testing.T) {
+		page, err := NewKeysetPaginated([]int{4, 3, 2}, 2, &Cursor[int]{Key: 5, Direction: CursorPrev}, identity)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3, 4}, page.Items)
+		assert.True(t, page.HasNext)
+		assert.NotEmpty(t, page.PrevCursor)
+	})
+
+	t.Run("Going backwards to the first page", func(t *testing.T) {
+		page, err := NewKeysetPaginated([]int{2, 1}, 2, &Cursor[int]{Key: 3, Direction: CursorPrev}, identity)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, page.Items)
+		assert.True(t, page.HasNext)
+		assert.Empty(t, page.PrevCursor)
+	})
+}
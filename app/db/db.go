@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -11,6 +13,11 @@ import (
 	"example/web-service-gin/app/appTracer"
 	"example/web-service-gin/app/clientContext"
 	"example/web-service-gin/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Database interface defines methods for interacting with the database.
@@ -20,6 +27,14 @@ import (
 type Database interface {
 	ExecContext(serviceName string, ctx context.Context, query string, args ...any) (*sql.Result, error)
 	QueryContext(serviceName string, ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	// QueryRowContext executes a query expected to return at most one row. Errors are deferred until Scan
+	QueryRowContext(serviceName string, ctx context.Context, query string, args ...any) *sql.Row
+	// Prepare returns the prepared statement of the query, preparing it on first use only
+	Prepare(serviceName string, ctx context.Context, query string) (*Stmt, error)
+	// CopyIn loads rows into a table with the COPY protocol. It must run in a transaction started by WithTx
+	CopyIn(serviceName string, ctx context.Context, table string, columns []string, rowCount int, row func(i int) []any) (int64, error)
+	// WithTx runs fn in a transaction joined by every call made with the context passed to fn
+	WithTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error
 	GetClient() *sql.DB
 	Close()
 }
@@ -38,6 +53,13 @@ type Database interface {
 type DatabaseImpl struct {
 	Client    *sql.DB
 	AppTracer appTracer.AppTracer
+
+	stmtsMu sync.RWMutex
+	stmts   map[string]*sql.Stmt
+
+	// replicas is nil when every query goes to the primary
+	replicas          *replicaPool
+	unregisterMetrics func() error
 }
 
 // NewDatabase creates and initializes a new Database instance.
@@ -52,32 +74,60 @@ type DatabaseImpl struct {
 //
 // The function performs the following steps:
 // 1. Constructs a data source name (DSN) string from the provided configuration.
-// 2. Opens a database connection using the specified driver and DSN.
-// 3. Pings the database to verify the connection.
-// 4. If successful, returns a new DatabaseImpl instance.
-// 5. If any step fails, it returns an error and closes any opened connection.
+// 2. Opens a database connection using the specified driver and DSN and applies the pool limits.
+// 3. Pings the database to verify the connection, retrying with backoff while it is unreachable.
+// 4. Exports the pool statistics as OpenTelemetry gauges.
+// 5. Opens a connection pool to every replica. Reads outside of transactions are spread over the
+//    healthy replicas, see QueryContext.
+// 6. If successful, returns a new DatabaseImpl instance.
+// 7. If any step fails, it returns an error and closes any opened connection.
 
 func NewDatabase(dbConfig config.DatabaseConfig, appTracer appTracer.AppTracer) (Database, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", dbConfig.Host, dbConfig.Port, dbConfig.User, dbConfig.Password, dbConfig.DBName, dbConfig.SSLMode)
-	db, err := sql.Open(dbConfig.Driver, dsn)
+	db, err := sql.Open(dbConfig.Driver, dataSourceName(dbConfig, dbConfig.Host, dbConfig.Port))
+	if err != nil {
+		return nil, err
+	}
+	configurePool(db, dbConfig)
+
+	err = connect(db, dbConfig)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	err = db.Ping()
+	unregisterMetrics, err := registerPoolMetrics(db, dbConfig.DBName, otel.GetMeterProvider())
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
+	replicas, err := openReplicas(dbConfig)
+	if err != nil {
+		unregisterMetrics()
+		db.Close()
+		return nil, err
+	}
+
 	dbImpl := &DatabaseImpl{
-		Client:    db,
-		AppTracer: appTracer,
+		Client:            db,
+		AppTracer:         appTracer,
+		replicas:          replicas,
+		unregisterMetrics: unregisterMetrics,
 	}
 
 	return dbImpl, nil
 }
 
+// dataSourceName builds the DSN of the server at host and port, with the credentials of the configuration
+func dataSourceName(dbConfig config.DatabaseConfig, host string, port int) string {
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", host, port, dbConfig.User, dbConfig.Password, dbConfig.DBName, dbConfig.SSLMode)
+	if dbConfig.ConnectTimeout > 0 {
+		// The driver only takes whole seconds
+		dsn += fmt.Sprintf(" connect_timeout=%d", int(math.Ceil(dbConfig.ConnectTimeout.Seconds())))
+	}
+	return dsn
+}
+
 // Close closes the database connection.
 //
 // This method should be called when the database is no longer needed to release
@@ -93,6 +143,11 @@ func NewDatabase(dbConfig config.DatabaseConfig, appTracer appTracer.AppTracer)
 //	defer db.Close()
 
 func (db *DatabaseImpl) Close() {
+	if db.unregisterMetrics != nil {
+		db.unregisterMetrics()
+	}
+	db.closeStmts()
+	db.replicas.close()
 	db.Client.Close()
 }
 
@@ -163,7 +218,8 @@ func (db *DatabaseImpl) GetClient() *sql.DB {
 
 // ExecContext executes a SQL query or statement that doesn't return rows
 //
-// This method executes a SQL query or statement that doesn't return rows. It creates
+// This method executes a SQL query or statement that doesn't return rows, inside the
+// transaction of the context when it was started by WithTx. It creates
 // a new span for tracing, executes the query, and records the database call in the
 // client context.
 //
@@ -190,8 +246,9 @@ func (db *DatabaseImpl) ExecContext(serviceName string, ctx context.Context, que
 	spanCtx, span := db.AppTracer.CreateSpan(ctx, serviceName)
 	defer span.End()
 
-	result, err := db.Client.ExecContext(spanCtx, query, args...)
+	result, err := db.executor(ctx).ExecContext(spanCtx, query, args...)
 	if err != nil {
+		markRetryable(ctx, err)
 		span.RecordError(err)
 		return nil, fmt.Errorf("error executing query: %w", err)
 	}
@@ -212,8 +269,10 @@ func (db *DatabaseImpl) ExecContext(serviceName string, ctx context.Context, que
 
 // QueryContext executes a SQL query that returns rows
 //
-// This method executes a SQL query that returns rows. It creates a new span for tracing,
-// executes the query, and records the database call in the client context.
+// This method executes a SQL query that returns rows, inside the transaction of the context
+// when it was started by WithTx. Outside of transactions the query goes to the next healthy
+// replica, or to the primary when there is none or the context was made by WithPrimary.
+// It creates a new span for tracing, executes the query, and records the database call in the client context.
 //
 // Parameters:
 //   - serviceName: The name of the service making the database call. Used for tracing.
@@ -239,8 +298,11 @@ func (db *DatabaseImpl) QueryContext(serviceName string, ctx context.Context, qu
 	spanCtx, span := db.AppTracer.CreateSpan(ctx, serviceName)
 	defer span.End()
 
-	rows, err := db.Client.QueryContext(spanCtx, query, args...)
+	reader, instance := db.reader(ctx)
+	span.SetAttributes(attribute.String("db.instance", instance))
+	rows, err := reader.QueryContext(spanCtx, query, args...)
 	if err != nil {
+		markRetryable(ctx, err)
 		span.RecordError(err)
 		return nil, err
 	}
@@ -257,3 +319,63 @@ func (db *DatabaseImpl) QueryContext(serviceName string, ctx context.Context, qu
 
 	return rows, nil
 }
+
+// QueryRowContext executes a SQL query that returns at most one row
+//
+// This method executes a SQL query expected to return a single row, such as a lookup by
+// primary key or a COUNT(*), inside the transaction of the context when it was started by
+// WithTx. Outside of transactions it is routed to a replica like QueryContext.
+// It creates a new span for tracing, executes the query, and records the database call in the client context.
+// sql.ErrNoRows is only returned by Scan and is not recorded as an error.
+//
+// Parameters:
+//   - serviceName: The name of the service making the database call. Used for tracing.
+//   - ctx: The context for the database operation.
+//   - query: The SQL query to execute.
+//   - args: Optional arguments for the SQL query.
+//
+// Returns:
+//   - *sql.Row: The row to scan. Any error executing the query is returned by Scan.
+//
+// Example usage:
+//
+//	var total int
+//	if err := db.QueryRowContext("UserService", ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+//	    // handle error
+//	}
+
+func (db *DatabaseImpl) QueryRowContext(serviceName string, ctx context.Context, query string, args ...any) *sql.Row {
+	startTime := time.Now()
+	spanCtx, span := db.AppTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	reader, instance := db.reader(ctx)
+	span.SetAttributes(attribute.String("db.instance", instance))
+	row := reader.QueryRowContext(spanCtx, query, args...)
+	err := row.Err()
+	if err != nil {
+		markRetryable(ctx, err)
+	}
+	db.recordCall(ctx, span, serviceName, query, startTime, err)
+
+	return row
+}
+
+// recordCall adds the call to the client context and sets the outcome on the span
+func (db *DatabaseImpl) recordCall(ctx context.Context, span trace.Span, serviceName string, query string, startTime time.Time, err error) {
+	clientContext.AddDatabaseCall(ctx, clientContext.DatabaseCall{
+		ServiceTransaction: clientContext.ServiceTransaction{
+			ServiceName: serviceName,
+			SpanId:      span.SpanContext().TraceID().String(),
+		},
+		Query:        query,
+		ResponseTime: time.Since(startTime),
+		Error:        err,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+}
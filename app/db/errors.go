@@ -1,32 +1,119 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"example/web-service-gin/app/apiErrors"
-	"net/http"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DatabaseErrorCode            = apiErrors.DatabaseErrorCode
+	NotFoundErrorCode            = apiErrors.DatabaseNotFoundCode
+	ConstraintViolationErrorCode = apiErrors.ConstraintViolationErrorCode
+	ConnectionErrorCode          = apiErrors.ConnectionErrorCode
+	UniqueViolationErrorCode     = apiErrors.UniqueViolationErrorCode
+	ForeignKeyViolationErrorCode = apiErrors.ForeignKeyViolationErrorCode
+	NotNullViolationErrorCode    = apiErrors.NotNullViolationErrorCode
+	SerializationErrorCode       = apiErrors.SerializationErrorCode
+	QueryCanceledErrorCode       = apiErrors.QueryCanceledErrorCode
+	TimeoutErrorCode             = apiErrors.TimeoutErrorCode
+	InvalidCursorErrorCode       = apiErrors.InvalidCursorErrorCode
 )
 
+// SQLSTATE codes and classes of the postgres errors mapped below
 const (
-	DatabaseErrorCode            apiErrors.ErrorCode = "database_error"
-	NotFoundErrorCode            apiErrors.ErrorCode = "not_found"
-	ConstraintViolationErrorCode apiErrors.ErrorCode = "constraint_violation"
-	ConnectionErrorCode          apiErrors.ErrorCode = "connection_error"
+	pqUniqueViolation      pq.ErrorCode = "23505"
+	pqForeignKeyViolation  pq.ErrorCode = "23503"
+	pqNotNullViolation     pq.ErrorCode = "23502"
+	pqSerializationFailure pq.ErrorCode = "40001"
+	pqDeadlockDetected     pq.ErrorCode = "40P01"
+	pqQueryCanceled        pq.ErrorCode = "57014"
+
+	pqIntegrityConstraintViolationClass pq.ErrorClass = "23"
+	pqConnectionExceptionClass          pq.ErrorClass = "08"
 )
 
-// TODO: use errors made in error module to not have http status codes here
-var NotFoundError = apiErrors.New(string(NotFoundErrorCode), "resource not found", http.StatusNotFound)
-var DatabaseError = apiErrors.New(string(DatabaseErrorCode), "data retrieval error", http.StatusInternalServerError)
-var ConstraintViolationError = apiErrors.New(string(ConstraintViolationErrorCode), "constraint violation", http.StatusBadRequest)
-var ConnectionError = apiErrors.New(string(ConnectionErrorCode), "connection error", http.StatusBadRequest)
+var NotFoundError = apiErrors.NewWithCode(NotFoundErrorCode, "resource not found")
+var DatabaseError = apiErrors.NewWithCode(DatabaseErrorCode, "data retrieval error")
+var ConstraintViolationError = apiErrors.NewWithCode(ConstraintViolationErrorCode, "constraint violation")
+var ConnectionError = apiErrors.NewWithCode(ConnectionErrorCode, "connection error")
+var UniqueViolationError = apiErrors.NewWithCode(UniqueViolationErrorCode, "resource already exists")
+var ForeignKeyViolationError = apiErrors.NewWithCode(ForeignKeyViolationErrorCode, "referenced resource does not exist or is still referenced")
+var NotNullViolationError = apiErrors.NewWithCode(NotNullViolationErrorCode, "missing required value")
+var SerializationError = apiErrors.NewWithCode(SerializationErrorCode, "concurrent update, please retry")
+var QueryCanceledError = apiErrors.NewWithCode(QueryCanceledErrorCode, "query canceled")
+var TimeoutError = apiErrors.NewWithCode(TimeoutErrorCode, "query timed out")
+var InvalidCursorError = apiErrors.NewWithCode(InvalidCursorErrorCode, "invalid cursor")
 
+// MapDBError translates a database error into the APIError returned to clients.
+//
+// Postgres errors are mapped by SQLSTATE. Constraint errors carry the name of the constraint,
+// table and column involved as Details; values and driver messages are never exposed since they
+// may contain user data.
 func MapDBError(err *error) *apiErrors.APIError {
-	var customErr *apiErrors.APIError
+	var pqErr *pq.Error
+	var netErr *net.OpError
+
+	switch {
+	case errors.Is(*err, sql.ErrNoRows):
+		return NotFoundError
+	case errors.Is(*err, context.DeadlineExceeded):
+		return TimeoutError
+	case errors.Is(*err, context.Canceled):
+		return QueryCanceledError
+	case errors.As(*err, &pqErr):
+		return mapPQError(pqErr)
+	case errors.Is(*err, driver.ErrBadConn), errors.Is(*err, sql.ErrConnDone), errors.As(*err, &netErr):
+		return ConnectionError
+	default:
+		return DatabaseError
+	}
+}
 
-	switch *err {
-	case sql.ErrNoRows:
-		customErr = NotFoundError
+func mapPQError(pqErr *pq.Error) *apiErrors.APIError {
+	switch {
+	case pqErr.Code == pqUniqueViolation:
+		return withDetails(UniqueViolationError, pqErr)
+	case pqErr.Code == pqForeignKeyViolation:
+		return withDetails(ForeignKeyViolationError, pqErr)
+	case pqErr.Code == pqNotNullViolation:
+		return withDetails(NotNullViolationError, pqErr)
+	case pqErr.Code.Class() == pqIntegrityConstraintViolationClass:
+		return withDetails(ConstraintViolationError, pqErr)
+	case pqErr.Code == pqSerializationFailure, pqErr.Code == pqDeadlockDetected:
+		return SerializationError
+	case pqErr.Code == pqQueryCanceled:
+		return QueryCanceledError
+	case pqErr.Code.Class() == pqConnectionExceptionClass:
+		return ConnectionError
 	default:
-		customErr = DatabaseError
+		return DatabaseError
+	}
+}
+
+// withDetails returns a copy of the error with the names of the schema objects involved.
+// The shared error is returned as is when postgres didn't report any.
+func withDetails(apiErr *apiErrors.APIError, pqErr *pq.Error) *apiErrors.APIError {
+	details := map[string]string{}
+	if pqErr.Constraint != "" {
+		details["constraint"] = pqErr.Constraint
 	}
-	return customErr
+	if pqErr.Table != "" {
+		details["table"] = pqErr.Table
+	}
+	if pqErr.Column != "" {
+		details["column"] = pqErr.Column
+	}
+	if len(details) == 0 {
+		return apiErr
+	}
+
+	detailed := *apiErr
+	detailed.Details = details
+	return &detailed
 }
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapDBError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"No rows", sql.ErrNoRows, NotFoundError},
+		{"Wrapped no rows", fmt.Errorf("scan: %w", sql.ErrNoRows), NotFoundError},
+		{"Deadline exceeded", context.DeadlineExceeded, TimeoutError},
+		{"Context canceled", context.Canceled, QueryCanceledError},
+		{"Unique violation without details", &pq.Error{Code: "23505"}, UniqueViolationError},
+		{"Check violation", &pq.Error{Code: "23514"}, ConstraintViolationError},
+		{"Serialization failure", &pq.Error{Code: "40001"}, SerializationError},
+		{"Deadlock", &pq.Error{Code: "40P01"}, SerializationError},
+		{"Query canceled", &pq.Error{Code: "57014"}, QueryCanceledError},
+		{"Connection failure", &pq.Error{Code: "08006"}, ConnectionError},
+		{"Other postgres error", &pq.Error{Code: "42601"}, DatabaseError},
+		{"Bad connection", driver.ErrBadConn, ConnectionError},
+		{"Closed connection", sql.ErrConnDone, ConnectionError},
+		{"Network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ConnectionError},
+		{"Unknown error", errors.New("unknown"), DatabaseError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MapDBError(&tt.err))
+		})
+	}
+}
+
+func TestMapDBErrorDetails(t *testing.T) {
+	t.Run("Foreign key violations name the constraint and table", func(t *testing.T) {
+		var err error = &pq.Error{Code: "23503", Constraint: "albums_artist_fkey", Table: "albums", Message: "key (artist_id)=(42) is not present"}
+
+		apiErr := MapDBError(&err)
+
+		assert.Equal(t, string(ForeignKeyViolationErrorCode), apiErr.Code)
+		assert.Equal(t, 409, apiErr.Status)
+		assert.Equal(t, map[string]string{"constraint": "albums_artist_fkey", "table": "albums"}, apiErr.Details)
+	})
+
+	t.Run("Not null violations name the column", func(t *testing.T) {
+		var err error = &pq.Error{Code: "23502", Table: "albums", Column: "title"}
+
+		apiErr := MapDBError(&err)
+
+		assert.Equal(t, string(NotNullViolationErrorCode), apiErr.Code)
+		assert.Equal(t, 400, apiErr.Status)
+		assert.Equal(t, map[string]string{"table": "albums", "column": "title"}, apiErr.Details)
+	})
+
+	t.Run("Shared errors are not modified", func(t *testing.T) {
+		var err error = &pq.Error{Code: "23505", Constraint: "albums_pkey"}
+
+		apiErr := MapDBError(&err)
+
+		assert.Equal(t, map[string]string{"constraint": "albums_pkey"}, apiErr.Details)
+		assert.Nil(t, UniqueViolationError.Details)
+	})
+}
@@ -1,5 +1,39 @@
 package db
 
+// Paginated is a single page of results along with the metadata needed to fetch the rest.
+// Offset pages fill Total, Page and TotalPages. Keyset pages fill NextCursor and PrevCursor instead.
 type Paginated[T any] struct {
-	Items []T `json:"items"`
+	Items      []T    `json:"items"`
+	Total      int    `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"totalPages,omitempty"`
+	HasNext    bool   `json:"hasNext"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// NewPaginated builds a page of items and derives totalPages and hasNext from the total row count.
+func NewPaginated[T any](items []T, total int, page int, limit int) *Paginated[T] {
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (total + limit - 1) / limit
+	}
+	return &Paginated[T]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+	}
+}
+
+// Offset returns the number of rows to skip for a 1 based page number.
+func Offset(page int, limit int) int {
+	offset := (page - 1) * limit
+	if offset < 0 {
+		return 0
+	}
+	return offset
 }
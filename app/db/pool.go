@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"example/web-service-gin/config"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultConnectTimeout = 5 * time.Second
+	defaultConnectBackoff = 500 * time.Millisecond
+	maxConnectBackoff     = 10 * time.Second
+
+	meterName = "example/web-service-gin/app/db"
+)
+
+// configurePool applies the pool limits set in the configuration
+func configurePool(db *sql.DB, dbConfig config.DatabaseConfig) {
+	if dbConfig.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	}
+	if dbConfig.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	}
+	if dbConfig.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	}
+	if dbConfig.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime)
+	}
+}
+
+// connect pings the database until it answers, waiting longer after each failed attempt.
+// It gives up after ConnectRetries retries and returns the last error.
+func connect(db *sql.DB, dbConfig config.DatabaseConfig) error {
+	timeout := dbConfig.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	backoff := dbConfig.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil || attempt >= dbConfig.ConnectRetries {
+			break
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"attempt": attempt + 1,
+			"retryIn": backoff.String(),
+			"error":   err.Error(),
+		}).Warn("database not reachable, retrying")
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+	if err != nil {
+		return fmt.Errorf("database not reachable after %d attempts: %w", dbConfig.ConnectRetries+1, err)
+	}
+	return nil
+}
+
+// registerPoolMetrics exports the pool statistics of db as gauges, read every time metrics are collected.
+// The returned func stops the export.
+func registerPoolMetrics(db *sql.DB, dbName string, meterProvider metric.MeterProvider) (func() error, error) {
+	meter := meterProvider.Meter(meterName)
+
+	openConns, err := meter.Int64ObservableGauge("db.pool.connections.open",
+		metric.WithDescription("Established connections, both in use and idle"))
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := meter.Int64ObservableGauge("db.pool.connections.in_use",
+		metric.WithDescription("Connections currently in use"))
+	if err != nil {
+		return nil, err
+	}
+	idle, err := meter.Int64ObservableGauge("db.pool.connections.idle",
+		metric.WithDescription("Idle connections"))
+	if err != nil {
+		return nil, err
+	}
+	waitCount, err := meter.Int64ObservableCounter("db.pool.wait_count",
+		metric.WithDescription("Connections waited for because the pool was exhausted"))
+	if err != nil {
+		return nil, err
+	}
+	waitDuration, err := meter.Float64ObservableCounter("db.pool.wait_duration",
+		metric.WithDescription("Time spent waiting for a connection"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := metric.WithAttributes(attribute.String("db.name", dbName))
+	registration, err := meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		stats := db.Stats()
+		observer.ObserveInt64(openConns, int64(stats.OpenConnections), attributes)
+		observer.ObserveInt64(inUse, int64(stats.InUse), attributes)
+		observer.ObserveInt64(idle, int64(stats.Idle), attributes)
+		observer.ObserveInt64(waitCount, stats.WaitCount, attributes)
+		observer.ObserveFloat64(waitDuration, stats.WaitDuration.Seconds(), attributes)
+		return nil
+	}, openConns, inUse, idle, waitCount, waitDuration)
+	if err != nil {
+		return nil, err
+	}
+	return registration.Unregister, nil
+}
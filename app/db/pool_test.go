@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example/web-service-gin/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConfigurePool(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	configurePool(mockDB, config.DatabaseConfig{MaxOpenConns: 7})
+
+	assert.Equal(t, 7, mockDB.Stats().MaxOpenConnections)
+}
+
+func TestConnect(t *testing.T) {
+	dbConfig := config.DatabaseConfig{ConnectRetries: 2, ConnectBackoff: time.Millisecond}
+
+	t.Run("Retries until the database answers", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer mockDB.Close()
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+		mock.ExpectPing()
+
+		assert.NoError(t, connect(mockDB, dbConfig))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Gives up after the last retry", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer mockDB.Close()
+		for range 3 {
+			mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+		}
+
+		err = connect(mockDB, dbConfig)
+
+		assert.EqualError(t, err, "database not reachable after 3 attempts: connection refused")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRegisterPoolMetrics(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	unregister, err := registerPoolMetrics(mockDB, "album-store", provider)
+	assert.NoError(t, err)
+
+	var metrics metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &metrics))
+	names := []string{}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			names = append(names, m.Name)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"db.pool.connections.open",
+		"db.pool.connections.in_use",
+		"db.pool.connections.idle",
+		"db.pool.wait_count",
+		"db.pool.wait_duration",
+	}, names)
+
+	assert.NoError(t, unregister())
+}
@@ -0,0 +1,399 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// identifier matches the table and column names the builders accept, optionally qualified by a table
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Order is one key of an ORDER BY
+type Order struct {
+	Column     string
+	Descending bool
+}
+
+// SelectQuery builds a SELECT with numbered placeholders.
+//
+// Conditions are written with ? placeholders, which Build numbers $1, $2, ... in the order they
+// appear in the query, so conditions can be added in any order without counting arguments.
+// Table, column and ORDER BY names must be plain identifiers, so values never reach the query text.
+//
+// Example usage:
+//
+//	query, args, err := db.Select("albums", "id", "title").
+//	    Where("artist ILIKE ?", artist).
+//	    Where("price <= ?", maxPrice).
+//	    OrderBy(db.Order{Column: "price", Descending: true}).
+//	    Limit(10).
+//	    Build()
+//	// SELECT id, title FROM albums WHERE artist ILIKE $1 AND price <= $2 ORDER BY price DESC LIMIT $3
+type SelectQuery struct {
+	table      string
+	columns    []string
+	count      bool
+	conditions []string
+	args       []any
+	orders     []Order
+	limit      *int
+	offset     *int
+	forUpdate  bool
+}
+
+// Select starts a query for the columns of the table
+func Select(table string, columns ...string) *SelectQuery {
+	return &SelectQuery{table: table, columns: columns}
+}
+
+// Where adds a condition, joined to the others with AND. Every ? in the condition takes the next of args.
+// Group alternatives in a single condition, e.g. Where("(artist ILIKE ? OR artist ILIKE ?)", a, b).
+func (q *SelectQuery) Where(condition string, args ...any) *SelectQuery {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// OrderBy adds keys to the ORDER BY
+func (q *SelectQuery) OrderBy(orders ...Order) *SelectQuery {
+	q.orders = append(q.orders, orders...)
+	return q
+}
+
+// Limit sets the LIMIT, passed as an argument
+func (q *SelectQuery) Limit(limit int) *SelectQuery {
+	q.limit = &limit
+	return q
+}
+
+// Offset sets the OFFSET, passed as an argument
+func (q *SelectQuery) Offset(offset int) *SelectQuery {
+	q.offset = &offset
+	return q
+}
+
+// ForUpdate locks the selected rows until the end of the transaction, so concurrent writers
+// wait for it instead of overwriting what it read. It only holds the locks inside a transaction.
+func (q *SelectQuery) ForUpdate() *SelectQuery {
+	q.forUpdate = true
+	return q
+}
+
+// Count returns a query counting the rows matching the conditions of q, without its order, limits and locks
+func (q *SelectQuery) Count() *SelectQuery {
+	return &SelectQuery{
+		table:      q.table,
+		count:      true,
+		conditions: append([]string(nil), q.conditions...),
+		args:       append([]any(nil), q.args...),
+	}
+}
+
+// Build returns the query text and its arguments.
+// It fails when a name is not a plain identifier or the placeholders don't match the arguments.
+func (q *SelectQuery) Build() (string, []any, error) {
+	if err := validIdentifiers(q.table); err != nil {
+		return "", nil, err
+	}
+
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	if q.count {
+		query.WriteString("COUNT(*)")
+	} else if len(q.columns) == 0 {
+		return "", nil, fmt.Errorf("select from %s has no columns", q.table)
+	} else {
+		if err := validIdentifiers(q.columns...); err != nil {
+			return "", nil, err
+		}
+		query.WriteString(strings.Join(q.columns, ", "))
+	}
+	query.WriteString(" FROM ")
+	query.WriteString(q.table)
+
+	writeWhere(&query, q.conditions)
+
+	if len(q.orders) > 0 {
+		keys := make([]string, 0, len(q.orders))
+		for _, order := range q.orders {
+			if err := validIdentifiers(order.Column); err != nil {
+				return "", nil, err
+			}
+			key := order.Column
+			if order.Descending {
+				key += " DESC"
+			}
+			keys = append(keys, key)
+		}
+		query.WriteString(" ORDER BY ")
+		query.WriteString(strings.Join(keys, ", "))
+	}
+
+	args := append([]any(nil), q.args...)
+	if q.limit != nil {
+		query.WriteString(" LIMIT ?")
+		args = append(args, *q.limit)
+	}
+	if q.offset != nil {
+		query.WriteString(" OFFSET ?")
+		args = append(args, *q.offset)
+	}
+	if q.forUpdate {
+		query.WriteString(" FOR UPDATE")
+	}
+
+	text, err := numberPlaceholders(query.String(), len(args))
+	if err != nil {
+		return "", nil, err
+	}
+	return text, args, nil
+}
+
+// InsertQuery builds a multi row INSERT with numbered placeholders
+//
+// Example usage:
+//
+//	insert := db.InsertInto("albums", db.Columns[Album]()...)
+//	for _, album := range albums {
+//	    insert.Values(db.FieldValues(album)...)
+//	}
+//	query, args, err := insert.Suffix("ON CONFLICT (id) DO NOTHING").Build()
+type InsertQuery struct {
+	table   string
+	columns []string
+	rows    [][]any
+	suffix  string
+}
+
+// InsertInto starts an INSERT into the columns of the table
+func InsertInto(table string, columns ...string) *InsertQuery {
+	return &InsertQuery{table: table, columns: columns}
+}
+
+// Values adds a row. It must hold one value per column
+func (q *InsertQuery) Values(values ...any) *InsertQuery {
+	q.rows = append(q.rows, values)
+	return q
+}
+
+// Suffix sets a clause written after the values, such as ON CONFLICT or RETURNING.
+// It is written as is, so it must not hold values.
+func (q *InsertQuery) Suffix(clause string) *InsertQuery {
+	q.suffix = clause
+	return q
+}
+
+// Build returns the query text and its arguments. It fails when the rows need more than MaxParameters arguments.
+func (q *InsertQuery) Build() (string, []any, error) {
+	if err := validIdentifiers(q.table); err != nil {
+		return "", nil, err
+	}
+	if err := validIdentifiers(q.columns...); err != nil {
+		return "", nil, err
+	}
+	if len(q.rows) == 0 {
+		return "", nil, fmt.Errorf("insert into %s has no rows", q.table)
+	}
+
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(q.columns)), ", ") + ")"
+	rows := make([]string, 0, len(q.rows))
+	args := make([]any, 0, len(q.rows)*len(q.columns))
+	for i, values := range q.rows {
+		if len(values) != len(q.columns) {
+			return "", nil, fmt.Errorf("row %d of the insert into %s has %d values for %d columns", i, q.table, len(values), len(q.columns))
+		}
+		rows = append(rows, row)
+		args = append(args, values...)
+	}
+	if len(args) > MaxParameters {
+		return "", nil, fmt.Errorf("insert into %s has %d arguments, more than the %d postgres accepts; split the rows with Chunk", q.table, len(args), MaxParameters)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", q.table, strings.Join(q.columns, ", "), strings.Join(rows, ", "))
+	if q.suffix != "" {
+		query += " " + q.suffix
+	}
+	text, err := numberPlaceholders(query, len(args))
+	if err != nil {
+		return "", nil, err
+	}
+	return text, args, nil
+}
+
+// UpdateQuery builds an UPDATE with numbered placeholders. Conditions are written like those of SelectQuery.
+//
+// Example usage:
+//
+//	query, args, err := db.Update("albums").
+//	    Set("title", title).
+//	    Set("price", price).
+//	    Where("id = ?", id).
+//	    Build()
+//	// UPDATE albums SET title = $1, price = $2 WHERE id = $3
+type UpdateQuery struct {
+	table      string
+	columns    []string
+	values     []any
+	conditions []string
+	args       []any
+}
+
+// Update starts an UPDATE of the table
+func Update(table string) *UpdateQuery {
+	return &UpdateQuery{table: table}
+}
+
+// Set assigns the value to the column
+func (q *UpdateQuery) Set(column string, value any) *UpdateQuery {
+	q.columns = append(q.columns, column)
+	q.values = append(q.values, value)
+	return q
+}
+
+// Where adds a condition, joined to the others with AND. Every ? in the condition takes the next of args.
+func (q *UpdateQuery) Where(condition string, args ...any) *UpdateQuery {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// Build returns the query text and its arguments. It fails without a column to set or without
+// conditions, as an UPDATE without conditions changes every row of the table.
+func (q *UpdateQuery) Build() (string, []any, error) {
+	if err := validIdentifiers(q.table); err != nil {
+		return "", nil, err
+	}
+	if err := validIdentifiers(q.columns...); err != nil {
+		return "", nil, err
+	}
+	if len(q.columns) == 0 {
+		return "", nil, fmt.Errorf("update of %s sets no columns", q.table)
+	}
+	if len(q.conditions) == 0 {
+		return "", nil, fmt.Errorf("update of %s has no conditions, it would change every row", q.table)
+	}
+
+	assignments := make([]string, 0, len(q.columns))
+	for _, column := range q.columns {
+		assignments = append(assignments, column+" = ?")
+	}
+	var query strings.Builder
+	query.WriteString("UPDATE ")
+	query.WriteString(q.table)
+	query.WriteString(" SET ")
+	query.WriteString(strings.Join(assignments, ", "))
+	writeWhere(&query, q.conditions)
+
+	args := append(append([]any(nil), q.values...), q.args...)
+	text, err := numberPlaceholders(query.String(), len(args))
+	if err != nil {
+		return "", nil, err
+	}
+	return text, args, nil
+}
+
+// DeleteQuery builds a DELETE with numbered placeholders. Conditions are written like those of SelectQuery.
+//
+// Example usage:
+//
+//	query, args, err := db.DeleteFrom("albums").Where("id = ?", id).Build()
+//	// DELETE FROM albums WHERE id = $1
+type DeleteQuery struct {
+	table      string
+	conditions []string
+	args       []any
+}
+
+// DeleteFrom starts a DELETE from the table
+func DeleteFrom(table string) *DeleteQuery {
+	return &DeleteQuery{table: table}
+}
+
+// Where adds a condition, joined to the others with AND. Every ? in the condition takes the next of args.
+func (q *DeleteQuery) Where(condition string, args ...any) *DeleteQuery {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// Build returns the query text and its arguments. It fails without conditions, as a DELETE without
+// conditions empties the table.
+func (q *DeleteQuery) Build() (string, []any, error) {
+	if err := validIdentifiers(q.table); err != nil {
+		return "", nil, err
+	}
+	if len(q.conditions) == 0 {
+		return "", nil, fmt.Errorf("delete from %s has no conditions, it would delete every row", q.table)
+	}
+
+	var query strings.Builder
+	query.WriteString("DELETE FROM ")
+	query.WriteString(q.table)
+	writeWhere(&query, q.conditions)
+
+	args := append([]any(nil), q.args...)
+	text, err := numberPlaceholders(query.String(), len(args))
+	if err != nil {
+		return "", nil, err
+	}
+	return text, args, nil
+}
+
+// writeWhere writes the WHERE clause joining the conditions with AND, if there are any
+func writeWhere(query *strings.Builder, conditions []string) {
+	if len(conditions) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(conditions, " AND "))
+	}
+}
+
+func validIdentifiers(names ...string) error {
+	for _, name := range names {
+		if !identifier.MatchString(name) {
+			return fmt.Errorf("%q is not a valid identifier", name)
+		}
+	}
+	return nil
+}
+
+// numberPlaceholders replaces every ? with $1, $2, ... and checks there is one per argument.
+// ?? is written as a literal ?, for the postgres operators using it.
+func numberPlaceholders(query string, argCount int) (string, error) {
+	var numbered strings.Builder
+	placeholders := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			numbered.WriteByte(query[i])
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == '?' {
+			numbered.WriteByte('?')
+			i++
+			continue
+		}
+		placeholders++
+		numbered.WriteString("$" + strconv.Itoa(placeholders))
+	}
+	if placeholders != argCount {
+		return "", fmt.Errorf("query has %d placeholders for %d arguments", placeholders, argCount)
+	}
+	return numbered.String(), nil
+}
+
+// MaxParameters is the most arguments postgres accepts in a single statement
+const MaxParameters = 65535
+
+// Chunk splits items into consecutive slices of at most size items, sharing the backing array of items.
+// Use it to keep multi row statements under MaxParameters.
+func Chunk[T any](items []T, size int) [][]T {
+	if size <= 0 {
+		size = len(items)
+	}
+	chunks := make([][]T, 0, (len(items)+size-1)/max(size, 1))
+	for start := 0; start < len(items); start += size {
+		chunks = append(chunks, items[start:min(start+size, len(items))])
+	}
+	return chunks
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectQuery(t *testing.T) {
+	t.Run("Numbers placeholders in the order they appear", func(t *testing.T) {
+		query, args, err := Select("albums", "id", "title").
+			Where("(artist ILIKE ? OR artist ILIKE ?)", "Miles Davis", "John Coltrane").
+			Where("price <= ?", 20.0).
+			OrderBy(Order{Column: "price", Descending: true}, Order{Column: "id"}).
+			Limit(10).
+			Offset(20).
+			Build()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id, title FROM albums WHERE (artist ILIKE $1 OR artist ILIKE $2) AND price <= $3 ORDER BY price DESC, id LIMIT $4 OFFSET $5", query)
+		assert.Equal(t, []any{"Miles Davis", "John Coltrane", 20.0, 10, 20}, args)
+	})
+
+	t.Run("Count keeps the conditions only", func(t *testing.T) {
+		selectQuery := Select("albums", "id").Where("artist = ?", "Miles Davis").OrderBy(Order{Column: "id"}).Limit(10)
+
+		query, args, err := selectQuery.Count().Build()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM albums WHERE artist = $1", query)
+		assert.Equal(t, []any{"Miles Davis"}, args)
+	})
+
+	t.Run("Locks the rows after the limits", func(t *testing.T) {
+		selectQuery := Select("albums", "id").Where("id = ?", "1").Limit(1).ForUpdate()
+
+		query, args, err := selectQuery.Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM albums WHERE id = $1 LIMIT $2 FOR UPDATE", query)
+		assert.Equal(t, []any{"1", 1}, args)
+
+		count, _, err := selectQuery.Count().Build()
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM albums WHERE id = $1", count)
+	})
+
+	t.Run("Double question marks are literal", func(t *testing.T) {
+		query, _, err := Select("albums", "id").Where("tags ?? ?", "jazz").Build()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM albums WHERE tags ? $1", query)
+	})
+
+	t.Run("Rejects names that are not identifiers", func(t *testing.T) {
+		_, _, err := Select("albums", "id").OrderBy(Order{Column: "price; DROP TABLE albums"}).Build()
+		assert.Error(t, err)
+
+		_, _, err = Select("albums", "id, (SELECT 1)").Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects placeholders without arguments", func(t *testing.T) {
+		_, _, err := Select("albums", "id").Where("artist = ? AND title = ?", "Miles Davis").Build()
+		assert.EqualError(t, err, "query has 2 placeholders for 1 arguments")
+	})
+}
+
+func TestInsertQuery(t *testing.T) {
+	t.Run("Builds one row of placeholders per value set", func(t *testing.T) {
+		query, args, err := InsertInto("albums", "id", "title").
+			Values("1", "Blue Train").
+			Values("2", "Kind of Blue").
+			Suffix("ON CONFLICT (id) DO NOTHING").
+			Build()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "INSERT INTO albums (id, title) VALUES ($1, $2), ($3, $4) ON CONFLICT (id) DO NOTHING", query)
+		assert.Equal(t, []any{"1", "Blue Train", "2", "Kind of Blue"}, args)
+	})
+
+	t.Run("Rejects rows not matching the columns", func(t *testing.T) {
+		_, _, err := InsertInto("albums", "id", "title").Values("1").Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects an insert without rows", func(t *testing.T) {
+		_, _, err := InsertInto("albums", "id").Build()
+		assert.Error(t, err)
+	})
+}
+
+func TestUpdateQuery(t *testing.T) {
+	t.Run("Numbers the assignments before the conditions", func(t *testing.T) {
+		query, args, err := Update("albums").
+			Set("title", "Blue Train").
+			Set("price", 56.99).
+			Where("id = ?", "1").
+			Build()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "UPDATE albums SET title = $1, price = $2 WHERE id = $3", query)
+		assert.Equal(t, []any{"Blue Train", 56.99, "1"}, args)
+	})
+
+	t.Run("Rejects an update of every row", func(t *testing.T) {
+		_, _, err := Update("albums").Set("price", 0).Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects an update without columns", func(t *testing.T) {
+		_, _, err := Update("albums").Where("id = ?", "1").Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Rejects columns that are not identifiers", func(t *testing.T) {
+		_, _, err := Update("albums").Set("price = 0, title", "x").Where("id = ?", "1").Build()
+		assert.Error(t, err)
+	})
+}
+
+func TestDeleteQuery(t *testing.T) {
+	t.Run("Builds the conditions", func(t *testing.T) {
+		query, args, err := DeleteFrom("albums").Where("id = ?", "1").Build()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "DELETE FROM albums WHERE id = $1", query)
+		assert.Equal(t, []any{"1"}, args)
+	})
+
+	t.Run("Rejects a delete of every row", func(t *testing.T) {
+		_, _, err := DeleteFrom("albums").Build()
+		assert.Error(t, err)
+	})
+}
+
+func TestInsertQueryParameterLimit(t *testing.T) {
+	insert := InsertInto("albums", "id")
+	for i := range MaxParameters + 1 {
+		insert.Values(i)
+	}
+
+	_, _, err := insert.Build()
+
+	assert.ErrorContains(t, err, "more than the 65535 postgres accepts")
+}
+
+func TestChunk(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, Chunk([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal(t, [][]int{{1, 2}}, Chunk([]int{1, 2}, 10))
+	assert.Empty(t, Chunk([]int{}, 2))
+}
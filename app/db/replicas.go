@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example/web-service-gin/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReplicaHealthInterval = 5 * time.Second
+	// primaryInstance names the primary in the db.instance span attribute
+	primaryInstance = "primary"
+)
+
+type primaryContextKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary. Use it to read back a write,
+// which the replicas may not have replayed yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// ReadsFromPrimary reports whether the reads of the context go to the primary, see WithPrimary
+func ReadsFromPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
+
+// replica is a read only copy of the database with its own connection pool and prepared statements
+type replica struct {
+	name    string
+	client  *sql.DB
+	healthy atomic.Bool
+
+	stmtsMu sync.RWMutex
+	stmts   map[string]*sql.Stmt
+}
+
+// stmt returns the statement of the query prepared on the replica.
+// Statements are prepared on first use, after the primary validated the query in Prepare.
+func (r *replica) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	r.stmtsMu.RLock()
+	stmt, ok := r.stmts[query]
+	r.stmtsMu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+
+	// Prepared without the lock, like in Prepare, so a slow replica doesn't hold up cached lookups
+	stmt, err := r.client.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	r.stmtsMu.Lock()
+	defer r.stmtsMu.Unlock()
+	if stored, ok := r.stmts[query]; ok {
+		stmt.Close()
+		return stored, nil
+	}
+	if r.stmts == nil {
+		r.stmts = map[string]*sql.Stmt{}
+	}
+	r.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (r *replica) close() {
+	r.stmtsMu.Lock()
+	for query, stmt := range r.stmts {
+		stmt.Close()
+		delete(r.stmts, query)
+	}
+	r.stmtsMu.Unlock()
+	r.client.Close()
+}
+
+// replicaPool hands out the healthy replicas in turn
+type replicaPool struct {
+	replicas []*replica
+	next     atomic.Uint64
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+func newReplicaPool(replicas []*replica) *replicaPool {
+	return &replicaPool{replicas: replicas, stop: make(chan struct{})}
+}
+
+// pick returns the next healthy replica, or nil when none is healthy
+func (p *replicaPool) pick() *replica {
+	if p == nil {
+		return nil
+	}
+	for range p.replicas {
+		r := p.replicas[(p.next.Add(1)-1)%uint64(len(p.replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// checkHealth pings every replica, bounding each ping by timeout
+func (p *replicaPool) checkHealth(timeout time.Duration) {
+	for _, r := range p.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.client.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			logrus.WithField("replica", r.name).Info("database replica is healthy, sending it reads")
+		} else {
+			logrus.WithFields(logrus.Fields{
+				"replica": r.name,
+				"error":   err.Error(),
+			}).Warn("database replica is unhealthy, sending its reads elsewhere")
+		}
+	}
+}
+
+// watch checks the health of the replicas every interval until close is called
+func (p *replicaPool) watch(interval time.Duration) {
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.checkHealth(interval)
+			}
+		}
+	}()
+}
+
+func (p *replicaPool) close() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.done.Wait()
+	for _, r := range p.replicas {
+		r.close()
+	}
+}
+
+// reader returns what a read runs on: the transaction of the context, the primary when the
+// context asks for it or no replica is healthy, or else the next healthy replica.
+// The name of the instance is returned for tracing.
+func (db *DatabaseImpl) reader(ctx context.Context) (executor, string) {
+	if tx := transactionFromContext(ctx); tx != nil {
+		return tx.tx, primaryInstance
+	}
+	if !ReadsFromPrimary(ctx) {
+		if r := db.replicas.pick(); r != nil {
+			return r.client, r.name
+		}
+	}
+	return db.Client, primaryInstance
+}
+
+// openReplicas connects to the replicas of the configuration. A replica that can't be reached
+// starts unhealthy instead of failing the startup, and receives reads once a health check passes.
+func openReplicas(dbConfig config.DatabaseConfig) (*replicaPool, error) {
+	if len(dbConfig.Replicas) == 0 {
+		return nil, nil
+	}
+	interval := dbConfig.ReplicaHealthInterval
+	if interval <= 0 {
+		interval = defaultReplicaHealthInterval
+	}
+
+	replicas := make([]*replica, 0, len(dbConfig.Replicas))
+	for _, replicaConfig := range dbConfig.Replicas {
+		client, err := sql.Open(dbConfig.Driver, dataSourceName(dbConfig, replicaConfig.Host, replicaConfig.Port))
+		if err != nil {
+			for _, r := range replicas {
+				r.close()
+			}
+			return nil, err
+		}
+		configurePool(client, dbConfig)
+		replicas = append(replicas, &replica{name: fmt.Sprintf("%s:%d", replicaConfig.Host, replicaConfig.Port), client: client})
+	}
+
+	pool := newReplicaPool(replicas)
+	pool.checkHealth(interval)
+	pool.watch(interval)
+	return pool, nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReplica(t *testing.T, name string, healthy bool) (*replica, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+
+	r := &replica{name: name, client: mockDB}
+	r.healthy.Store(healthy)
+	return r, mock
+}
+
+func TestReplicaRouting(t *testing.T) {
+	t.Run("Reads go to the replicas in turn", func(t *testing.T) {
+		database, primary, ctx := setupTestDatabase(t)
+		first, firstMock := newTestReplica(t, "replica-1", true)
+		second, secondMock := newTestReplica(t, "replica-2", true)
+		database.replicas = newReplicaPool([]*replica{first, second})
+		firstMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+		secondMock.ExpectQuery("SELECT 2").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(2))
+
+		_, err := database.QueryContext("test", ctx, "SELECT 1")
+		assert.NoError(t, err)
+		_, err = database.QueryContext("test", ctx, "SELECT 2")
+		assert.NoError(t, err)
+
+		assert.NoError(t, firstMock.ExpectationsWereMet())
+		assert.NoError(t, secondMock.ExpectationsWereMet())
+		assert.NoError(t, primary.ExpectationsWereMet())
+	})
+
+	t.Run("Writes go to the primary", func(t *testing.T) {
+		database, primary, ctx := setupTestDatabase(t)
+		r, replicaMock := newTestReplica(t, "replica-1", true)
+		database.replicas = newReplicaPool([]*replica{r})
+		primary.ExpectExec("DELETE FROM albums").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err := database.ExecContext("test", ctx, "DELETE FROM albums")
+
+		assert.NoError(t, err)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replicaMock.ExpectationsWereMet())
+	})
+
+	t.Run("WithPrimary reads from the primary", func(t *testing.T) {
+		database, primary, ctx := setupTestDatabase(t)
+		r, replicaMock := newTestReplica(t, "replica-1", true)
+		database.replicas = newReplicaPool([]*replica{r})
+		primary.ExpectQuery("SELECT title").WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Blue Train"))
+
+		var title string
+		err := database.QueryRowContext("test", WithPrimary(ctx), "SELECT title FROM albums").Scan(&title)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Blue Train", title)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replicaMock.ExpectationsWereMet())
+	})
+
+	t.Run("Transactional reads go to the primary", func(t *testing.T) {
+		database, primary, ctx := setupTestDatabase(t)
+		r, replicaMock := newTestReplica(t, "replica-1", true)
+		database.replicas = newReplicaPool([]*replica{r})
+		primary.ExpectBegin()
+		primary.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+		primary.ExpectCommit()
+
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			rows, err := database.QueryContext("test", ctx, "SELECT 1")
+			if err == nil {
+				rows.Close()
+			}
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replicaMock.ExpectationsWereMet())
+	})
+
+	t.Run("Unhealthy replicas are skipped", func(t *testing.T) {
+		database, primary, ctx := setupTestDatabase(t)
+		r, replicaMock := newTestReplica(t, "replica-1", false)
+		database.replicas = newReplicaPool([]*replica{r})
+		primary.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+		_, err := database.QueryContext("test", ctx, "SELECT 1")
+
+		assert.NoError(t, err)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replicaMock.ExpectationsWereMet())
+	})
+
+	t.Run("Prepared reads are prepared on the replica", func(t *testing.T) {
+		database, primary, ctx := setupTestDatabase(t)
+		r, replicaMock := newTestReplica(t, "replica-1", true)
+		database.replicas = newReplicaPool([]*replica{r})
+		primary.ExpectPrepare("SELECT title FROM albums WHERE id")
+		replicaMock.ExpectPrepare("SELECT title FROM albums WHERE id").
+			ExpectQuery().WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Blue Train"))
+
+		stmt, err := database.Prepare("test", ctx, "SELECT title FROM albums WHERE id = $1")
+		assert.NoError(t, err)
+		var title string
+		err = stmt.QueryRowContext("test", ctx, "1").Scan(&title)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Blue Train", title)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replicaMock.ExpectationsWereMet())
+	})
+}
+
+func TestReplicaHealthChecks(t *testing.T) {
+	r, replicaMock := newTestReplica(t, "replica-1", true)
+	pool := newReplicaPool([]*replica{r})
+
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	pool.checkHealth(time.Second)
+	assert.False(t, r.healthy.Load())
+	assert.Nil(t, pool.pick())
+
+	replicaMock.ExpectPing()
+	pool.checkHealth(time.Second)
+	assert.True(t, r.healthy.Load())
+	assert.Equal(t, r, pool.pick())
+
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// column is a struct field mapped to a column by its `db` tag
+type column struct {
+	name  string
+	index []int
+}
+
+// columnsByType caches the columns of every struct type scanned so far
+var columnsByType sync.Map
+
+// columnsOf returns the columns mapped by the `db` tags of the fields of T, in field order.
+// Fields without a tag, or tagged "-", are not mapped. Embedded structs are mapped as if their
+// fields belonged to T.
+func columnsOf[T any]() []column {
+	typ := reflect.TypeFor[T]()
+	if cached, ok := columnsByType.Load(typ); ok {
+		return cached.([]column)
+	}
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("db: %s is not a struct, only structs can be mapped to columns", typ))
+	}
+
+	var columns []column
+	for _, field := range reflect.VisibleFields(typ) {
+		name, ok := field.Tag.Lookup("db")
+		if !ok || name == "-" || !field.IsExported() {
+			continue
+		}
+		columns = append(columns, column{name: name, index: field.Index})
+	}
+	columnsByType.Store(typ, columns)
+	return columns
+}
+
+// Columns returns the column names mapped by the `db` tags of T, in field order
+//
+// Example usage:
+//
+//	type User struct {
+//	    ID   string `db:"id"`
+//	    Name string `db:"name"`
+//	}
+//
+//	db.Select("users", db.Columns[User]()...) // SELECT id, name FROM users
+func Columns[T any]() []string {
+	columns := columnsOf[T]()
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.name)
+	}
+	return names
+}
+
+// FieldValues returns the values of the mapped fields of v, in the order of Columns
+func FieldValues[T any](v T) []any {
+	value := reflect.ValueOf(v)
+	columns := columnsOf[T]()
+	values := make([]any, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, value.FieldByIndex(column.index).Interface())
+	}
+	return values
+}
+
+// ScanAll reads every row into a T, matching the result columns to the `db` tags of T by name,
+// and closes rows. A result column without a matching field is an error, so a query selecting
+// more than T holds fails instead of silently dropping data.
+//
+// Example usage:
+//
+//	rows, err := db.QueryContext("UserService", ctx, "SELECT id, name FROM users")
+//	if err != nil {
+//	    // handle error
+//	}
+//	users, err := db.ScanAll[User](rows)
+func ScanAll[T any](rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	resultColumns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := fieldIndexes[T](resultColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	for rows.Next() {
+		var item T
+		value := reflect.ValueOf(&item).Elem()
+		dest := make([]any, len(indexes))
+		for i, index := range indexes {
+			dest[i] = value.FieldByIndex(index).Addr().Interface()
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ScanOne reads a single row into a T. sql.Row doesn't expose its columns, so the query must
+// select the columns of T in the order of Columns. A query without rows returns sql.ErrNoRows.
+//
+// Example usage:
+//
+//	user, err := db.ScanOne[User](db.QueryRowContext("UserService", ctx, "SELECT id, name FROM users WHERE id = $1", id))
+func ScanOne[T any](row *sql.Row) (*T, error) {
+	var item T
+	value := reflect.ValueOf(&item).Elem()
+	columns := columnsOf[T]()
+	dest := make([]any, len(columns))
+	for i, column := range columns {
+		dest[i] = value.FieldByIndex(column.index).Addr().Interface()
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// fieldIndexes returns the index of the field of T each result column is scanned into
+func fieldIndexes[T any](resultColumns []string) ([][]int, error) {
+	byName := map[string][]int{}
+	for _, column := range columnsOf[T]() {
+		byName[column.name] = column.index
+	}
+
+	indexes := make([][]int, len(resultColumns))
+	for i, name := range resultColumns {
+		index, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("column %s has no field tagged db:%q in %s", name, name, reflect.TypeFor[T]())
+		}
+		indexes[i] = index
+	}
+	return indexes, nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	ID       string  `db:"id"`
+	Title    string  `db:"title"`
+	Price    float64 `db:"price"`
+	Internal string  `db:"-"`
+	Comment  string
+}
+
+func TestColumns(t *testing.T) {
+	assert.Equal(t, []string{"id", "title", "price"}, Columns[testRecord]())
+	assert.Equal(t, []any{"1", "Blue Train", 9.99}, FieldValues(testRecord{ID: "1", Title: "Blue Train", Price: 9.99, Comment: "ignored"}))
+}
+
+func TestScanAll(t *testing.T) {
+	t.Run("Matches columns by name", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"price", "id", "title"}).
+			AddRow(9.99, "1", "Blue Train").
+			AddRow(14.99, "2", "Kind of Blue"))
+
+		rows, err := database.QueryContext("test", ctx, "SELECT price, id, title FROM albums")
+		assert.NoError(t, err)
+		records, err := ScanAll[testRecord](rows)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []testRecord{
+			{ID: "1", Title: "Blue Train", Price: 9.99},
+			{ID: "2", Title: "Kind of Blue", Price: 14.99},
+		}, records)
+	})
+
+	t.Run("Fails on columns without a field", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "artist"}).AddRow("1", "John Coltrane"))
+
+		rows, err := database.QueryContext("test", ctx, "SELECT id, artist FROM albums")
+		assert.NoError(t, err)
+		_, err = ScanAll[testRecord](rows)
+
+		assert.EqualError(t, err, `column artist has no field tagged db:"artist" in db.testRecord`)
+	})
+}
+
+func TestScanOne(t *testing.T) {
+	t.Run("Scans the columns in field order", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "title", "price"}).AddRow("1", "Blue Train", 9.99))
+
+		record, err := ScanOne[testRecord](database.QueryRowContext("test", ctx, "SELECT id, title, price FROM albums WHERE id = $1", "1"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, &testRecord{ID: "1", Title: "Blue Train", Price: 9.99}, record)
+	})
+
+	t.Run("Returns ErrNoRows without rows", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "title", "price"}))
+
+		record, err := ScanOne[testRecord](database.QueryRowContext("test", ctx, "SELECT id, title, price FROM albums WHERE id = $1", "404"))
+
+		assert.Nil(t, record)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Stmt is a prepared statement shared by every caller of the same query text.
+// It runs inside the transaction of the context when there is one, like the Database methods.
+type Stmt struct {
+	db    *DatabaseImpl
+	query string
+	stmt  *sql.Stmt
+}
+
+// Prepare returns the prepared statement of the query
+//
+// Statements are cached by query text for the lifetime of the Database, so the query is only
+// prepared on the first call and every later call reuses it. The preparation is traced and
+// recorded in the client context as "PREPARE <query>". Only prepare queries with a fixed text;
+// queries built from user input would grow the cache without bound.
+//
+// Parameters:
+//   - serviceName: The name of the service making the database call. Used for tracing.
+//   - ctx: The context for the database operation.
+//   - query: The SQL query to prepare.
+//
+// Returns:
+//   - *Stmt: The prepared statement.
+//   - error: An error if the preparation fails.
+//
+// Example usage:
+//
+//	stmt, err := db.Prepare("UserService", ctx, "SELECT id, name FROM users WHERE id = $1")
+//	if err != nil {
+//	    // handle error
+//	}
+//	err = stmt.QueryRowContext("UserService", ctx, userId).Scan(&user.ID, &user.Name)
+
+func (db *DatabaseImpl) Prepare(serviceName string, ctx context.Context, query string) (*Stmt, error) {
+	db.stmtsMu.RLock()
+	stmt, ok := db.stmts[query]
+	db.stmtsMu.RUnlock()
+	if ok {
+		return &Stmt{db: db, query: query, stmt: stmt}, nil
+	}
+
+	startTime := time.Now()
+	spanCtx, span := db.AppTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	// The lock isn't held while preparing, so a slow preparation doesn't hold up the lookups of
+	// every other statement. Statements are prepared on the pool so they outlive the transaction
+	// they were first used in.
+	stmt, err := db.Client.PrepareContext(spanCtx, query)
+	db.recordCall(ctx, span, serviceName, "PREPARE "+query, startTime, err)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{db: db, query: query, stmt: db.storeStmt(query, stmt)}, nil
+}
+
+// storeStmt caches the statement of the query and returns it, or returns the statement another
+// caller prepared meanwhile and closes this one
+func (db *DatabaseImpl) storeStmt(query string, stmt *sql.Stmt) *sql.Stmt {
+	db.stmtsMu.Lock()
+	defer db.stmtsMu.Unlock()
+
+	if stored, ok := db.stmts[query]; ok {
+		stmt.Close()
+		return stored
+	}
+	if db.stmts == nil {
+		db.stmts = map[string]*sql.Stmt{}
+	}
+	db.stmts[query] = stmt
+	return stmt
+}
+
+func (db *DatabaseImpl) closeStmts() {
+	db.stmtsMu.Lock()
+	defer db.stmtsMu.Unlock()
+	for query, stmt := range db.stmts {
+		stmt.Close()
+		delete(db.stmts, query)
+	}
+}
+
+// forContext returns the statement bound to the transaction of the context, if any.
+// The bound statement is closed with the transaction.
+func (s *Stmt) forContext(ctx context.Context) *sql.Stmt {
+	if tx := transactionFromContext(ctx); tx != nil {
+		return tx.tx.StmtContext(ctx, s.stmt)
+	}
+	return s.stmt
+}
+
+// forRead returns the statement a read runs, prepared on the replica Database.QueryContext would use.
+// The primary serves the read when the statement can't be prepared on the replica.
+func (s *Stmt) forRead(ctx context.Context) (*sql.Stmt, string) {
+	if InTx(ctx) || ReadsFromPrimary(ctx) {
+		return s.forContext(ctx), primaryInstance
+	}
+	if r := s.db.replicas.pick(); r != nil {
+		if stmt, err := r.stmt(ctx, s.query); err == nil {
+			return stmt, r.name
+		}
+	}
+	return s.stmt, primaryInstance
+}
+
+// ExecContext executes the prepared statement with the arguments, tracing it like Database.ExecContext
+func (s *Stmt) ExecContext(serviceName string, ctx context.Context, args ...any) (*sql.Result, error) {
+	startTime := time.Now()
+	spanCtx, span := s.db.AppTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	result, err := s.forContext(ctx).ExecContext(spanCtx, args...)
+	if err != nil {
+		markRetryable(ctx, err)
+	}
+	s.db.recordCall(ctx, span, serviceName, s.query, startTime, err)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryContext executes the prepared statement with the arguments, routing and tracing it like Database.QueryContext
+func (s *Stmt) QueryContext(serviceName string, ctx context.Context, args ...any) (*sql.Rows, error) {
+	startTime := time.Now()
+	spanCtx, span := s.db.AppTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	stmt, instance := s.forRead(ctx)
+	span.SetAttributes(attribute.String("db.instance", instance))
+	rows, err := stmt.QueryContext(spanCtx, args...)
+	if err != nil {
+		markRetryable(ctx, err)
+	}
+	s.db.recordCall(ctx, span, serviceName, s.query, startTime, err)
+	return rows, err
+}
+
+// QueryRowContext executes the prepared statement with the arguments, routing and tracing it like Database.QueryRowContext
+func (s *Stmt) QueryRowContext(serviceName string, ctx context.Context, args ...any) *sql.Row {
+	startTime := time.Now()
+	spanCtx, span := s.db.AppTracer.CreateSpan(ctx, serviceName)
+	defer span.End()
+
+	stmt, instance := s.forRead(ctx)
+	span.SetAttributes(attribute.String("db.instance", instance))
+	row := stmt.QueryRowContext(spanCtx, args...)
+	err := row.Err()
+	if err != nil {
+		markRetryable(ctx, err)
+	}
+	s.db.recordCall(ctx, span, serviceName, s.query, startTime, err)
+	return row
+}
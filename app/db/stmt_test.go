@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRowContext(t *testing.T) {
+	t.Run("Scans the row and records the call", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM albums").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		var total int
+		err := database.QueryRowContext("test", ctx, "SELECT COUNT(*) FROM albums").Scan(&total)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []string{"SELECT COUNT(*) FROM albums"}, recordedQueries(ctx))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No rows is returned by Scan and not recorded as an error", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectQuery("SELECT id FROM albums").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		var id string
+		err := database.QueryRowContext("test", ctx, "SELECT id FROM albums WHERE id = $1", "404").Scan(&id)
+
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.NoError(t, clientContextCalls(ctx)[0].Error)
+	})
+}
+
+func TestPrepare(t *testing.T) {
+	query := "SELECT id, title FROM albums WHERE id = $1"
+
+	t.Run("Prepares each query once", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		prepared := mock.ExpectPrepare("SELECT id, title FROM albums WHERE id = \\$1").WillBeClosed()
+		prepared.ExpectQuery().WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow("1", "Blue Train"))
+		prepared.ExpectQuery().WithArgs("2").WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow("2", "Jeru"))
+
+		for _, id := range []string{"1", "2"} {
+			stmt, err := database.Prepare("test", ctx, query)
+			assert.NoError(t, err)
+
+			var gotId, title string
+			assert.NoError(t, stmt.QueryRowContext("test", ctx, id).Scan(&gotId, &title))
+			assert.Equal(t, id, gotId)
+		}
+
+		assert.Equal(t, []string{"PREPARE " + query, query, query}, recordedQueries(ctx))
+		database.Close()
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Runs inside the transaction of the context", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectPrepare("UPDATE albums SET title = \\$2 WHERE id = \\$1")
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE albums SET title = \\$2 WHERE id = \\$1").WithArgs("1", "Giant Steps").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		stmt, err := database.Prepare("test", ctx, "UPDATE albums SET title = $2 WHERE id = $1")
+		assert.NoError(t, err)
+
+		err = database.WithTx(ctx, nil, func(ctx context.Context) error {
+			_, err := stmt.ExecContext("test", ctx, "1", "Giant Steps")
+			return err
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("A slow preparation doesn't hold up the prepared statements", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectPrepare("SELECT id, title FROM albums WHERE id = \\$1")
+		mock.ExpectPrepare("SELECT slow").WillDelayFor(500 * time.Millisecond)
+
+		_, err := database.Prepare("test", ctx, query)
+		assert.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := database.Prepare("test", ctx, "SELECT slow")
+			assert.NoError(t, err)
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		startTime := time.Now()
+		_, err = database.Prepare("test", ctx, query)
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(startTime), 100*time.Millisecond)
+
+		<-done
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Preparation errors are returned and not cached", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectPrepare("SELECT broken").WillReturnError(sql.ErrConnDone)
+
+		_, err := database.Prepare("test", ctx, "SELECT broken")
+		assert.Equal(t, sql.ErrConnDone, err)
+		assert.Empty(t, database.stmts)
+	})
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	txServiceName = "dbTransaction"
+	// maxTxAttempts is how many times a transaction failing to serialize is run before giving up
+	maxTxAttempts = 3
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+type txContextKey struct{}
+
+// transaction is the state of a transaction kept in the context
+type transaction struct {
+	tx *sql.Tx
+	// retryable is set when a statement of the transaction failed to serialize. Repositories map
+	// database errors to APIErrors, so the cause can't be read back from the error fn returns.
+	retryable bool
+	// afterCommit are the functions registered with AfterCommit, run once the transaction is committed
+	afterCommit []func(ctx context.Context)
+}
+
+// executor is what a statement runs on, either the connection pool or the transaction in the context
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func transactionFromContext(ctx context.Context) *transaction {
+	tx, _ := ctx.Value(txContextKey{}).(*transaction)
+	return tx
+}
+
+// InTx reports whether the context carries a transaction started by WithTx
+func InTx(ctx context.Context) bool {
+	return transactionFromContext(ctx) != nil
+}
+
+// AfterCommit runs fn once the transaction of the context is committed, or right away when the
+// context carries no transaction. Use it for side effects that must not be seen before the write,
+// such as purging a cache that a concurrent read would otherwise refill with the old rows.
+//
+// fn is dropped when the transaction is rolled back, and registrations from a failed attempt of a
+// retried transaction don't carry over, so fn runs at most once. It gets the context WithTx was
+// called with.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if tx := transactionFromContext(ctx); tx != nil {
+		tx.afterCommit = append(tx.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+// executor returns the transaction in the context, or the connection pool when there is none
+func (db *DatabaseImpl) executor(ctx context.Context) executor {
+	if tx := transactionFromContext(ctx); tx != nil {
+		return tx.tx
+	}
+	return db.Client
+}
+
+// WithTx runs fn in a transaction. Every query made with the context passed
+// to fn joins the transaction, so repositories don't need to know they are part of one.
+//
+// The transaction is committed when fn returns nil and rolled back when it returns an error or panics.
+// The panic is raised again after the rollback. Calling WithTx with a context that already carries a
+// transaction runs fn in that transaction, leaving the commit to the outermost call.
+//
+// A transaction failing with a serialization failure or a deadlock is run again, up to 3 times,
+// so fn must be safe to call more than once. Side effects that must wait for the commit go
+// through AfterCommit.
+func (db *DatabaseImpl) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	if InTx(ctx) {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		var retryable bool
+		retryable, err = db.runTx(ctx, opts, fn)
+		if err == nil || !retryable || attempt == maxTxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+	return err
+}
+
+// runTx runs one attempt of the transaction and reports whether it can be retried when it fails
+func (db *DatabaseImpl) runTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) (retryable bool, err error) {
+	startTime := time.Now()
+	spanCtx, span := db.AppTracer.CreateSpan(ctx, txServiceName)
+	tx, err := db.Client.BeginTx(spanCtx, opts)
+	db.recordTxCall(ctx, span, "BEGIN", startTime, err)
+	span.End()
+	if err != nil {
+		return isRetryable(err), fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	state := &transaction{tx: tx}
+	txCtx := context.WithValue(ctx, txContextKey{}, state)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			db.rollback(ctx, tx)
+			panic(recovered)
+		}
+	}()
+
+	if err := fn(txCtx); err != nil {
+		db.rollback(ctx, tx)
+		return state.retryable || isRetryable(err), err
+	}
+
+	startTime = time.Now()
+	spanCtx, span = db.AppTracer.CreateSpan(ctx, txServiceName)
+	defer span.End()
+	err = tx.Commit()
+	db.recordTxCall(ctx, span, "COMMIT", startTime, err)
+	if err != nil {
+		return isRetryable(err), fmt.Errorf("error committing transaction: %w", err)
+	}
+	for _, fn := range state.afterCommit {
+		fn(ctx)
+	}
+	return false, nil
+}
+
+func (db *DatabaseImpl) rollback(ctx context.Context, tx *sql.Tx) {
+	startTime := time.Now()
+	_, span := db.AppTracer.CreateSpan(ctx, txServiceName)
+	defer span.End()
+
+	err := tx.Rollback()
+	db.recordTxCall(ctx, span, "ROLLBACK", startTime, err)
+}
+
+func (db *DatabaseImpl) recordTxCall(ctx context.Context, span trace.Span, statement string, startTime time.Time, err error) {
+	db.recordCall(ctx, span, txServiceName, statement, startTime, err)
+	span.SetAttributes(attribute.String("db.statement", statement))
+}
+
+// markRetryable flags the transaction in the context when err means it should be run again
+func markRetryable(ctx context.Context, err error) {
+	if tx := transactionFromContext(ctx); tx != nil && isRetryable(err) {
+		tx.retryable = true
+	}
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"example/web-service-gin/app/clientContext"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testTracer can't come from testUtils, which imports this package
+type testTracer struct{}
+
+func (t *testTracer) CreateSpan(ctx context.Context, serviceName string) (context.Context, trace.Span) {
+	return ctx, trace.SpanFromContext(ctx)
+}
+
+func setupTestDatabase(t *testing.T) (*DatabaseImpl, sqlmock.Sqlmock, context.Context) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+
+	ctx := context.WithValue(context.Background(), clientContext.ClientContextKey, &clientContext.ClientContext{})
+	return &DatabaseImpl{Client: mockDB, AppTracer: &testTracer{}}, mock, ctx
+}
+
+func clientContextCalls(ctx context.Context) []clientContext.DatabaseCall {
+	return clientContext.GetClientContext(ctx).DatabaseCalls()
+}
+
+func recordedQueries(ctx context.Context) []string {
+	queries := []string{}
+	for _, call := range clientContextCalls(ctx) {
+		queries = append(queries, call.Query)
+	}
+	return queries
+}
+
+func TestWithTx(t *testing.T) {
+	t.Run("Commits when fn succeeds", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("TRUNCATE TABLE albums").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO albums").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			assert.True(t, InTx(ctx))
+			if _, err := database.ExecContext("test", ctx, "TRUNCATE TABLE albums"); err != nil {
+				return err
+			}
+			_, err := database.ExecContext("test", ctx, "INSERT INTO albums VALUES (1)")
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, []string{"BEGIN", "TRUNCATE TABLE albums", "INSERT INTO albums VALUES (1)", "COMMIT"}, recordedQueries(ctx))
+	})
+
+	t.Run("Rolls back when fn fails", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		fnErr := errors.New("failed")
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			return fnErr
+		})
+
+		assert.Equal(t, fnErr, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, []string{"BEGIN", "ROLLBACK"}, recordedQueries(ctx))
+	})
+
+	t.Run("Rolls back and panics again when fn panics", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.PanicsWithValue(t, "boom", func() {
+			database.WithTx(ctx, nil, func(ctx context.Context) error {
+				panic("boom")
+			})
+		})
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nested calls join the outer transaction", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM albums").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			return database.WithTx(ctx, nil, func(ctx context.Context) error {
+				_, err := database.ExecContext("test", ctx, "DELETE FROM albums")
+				return err
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Retries serialization failures", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE albums").WillReturnError(&pq.Error{Code: pqSerializationFailure})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE albums").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		attempts := 0
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			attempts++
+			if _, err := database.ExecContext("test", ctx, "UPDATE albums SET price = 1"); err != nil {
+				// Repositories hide the cause behind an APIError
+				return MapDBError(&err)
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Gives up after the last attempt", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		for range maxTxAttempts {
+			mock.ExpectBegin()
+			mock.ExpectCommit().WillReturnError(&pq.Error{Code: pqDeadlockDetected})
+		}
+
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			return nil
+		})
+
+		assert.Error(t, err)
+		assert.True(t, isRetryable(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Statements outside the transaction use the pool", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.False(t, InTx(ctx))
+		_, err := database.ExecContext("test", ctx, "SELECT 1")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAfterCommit(t *testing.T) {
+	t.Run("Runs right away outside of a transaction", func(t *testing.T) {
+		_, _, ctx := setupTestDatabase(t)
+
+		calls := 0
+		AfterCommit(ctx, func(ctx context.Context) { calls++ })
+
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Runs once the transaction is committed", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		calls := 0
+		err := database.WithTx(ctx, nil, func(txCtx context.Context) error {
+			AfterCommit(txCtx, func(ctx context.Context) {
+				calls++
+				assert.False(t, InTx(ctx))
+			})
+			assert.Equal(t, 0, calls)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Is dropped when the transaction is rolled back", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		calls := 0
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			AfterCommit(ctx, func(ctx context.Context) { calls++ })
+			return errors.New("failed")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("Runs once when the transaction is retried", func(t *testing.T) {
+		database, mock, ctx := setupTestDatabase(t)
+		mock.ExpectBegin()
+		mock.ExpectCommit().WillReturnError(&pq.Error{Code: pqSerializationFailure})
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		calls := 0
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			AfterCommit(ctx, func(ctx context.Context) { calls++ })
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
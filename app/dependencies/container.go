@@ -4,13 +4,23 @@ import (
 	"example/web-service-gin/app/appTracer"
 	"example/web-service-gin/app/cache"
 	"example/web-service-gin/app/db"
+	"example/web-service-gin/app/logger"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Dependencies struct {
-	Cache  cache.Cacher
-	DB     db.Database
-	Router *gin.Engine
-	Tracer appTracer.AppTracer
+	Cache cache.Cacher
+	// CacheStatus reports the breaker in front of Cache, nil when the cache has none
+	CacheStatus cache.StatusReporter
+	// Invalidations broadcasts evictions of data held in process to every running instance
+	Invalidations cache.InvalidationBus
+	DB            db.Database
+	Router        *gin.Engine
+	Tracer        appTracer.AppTracer
+	// HTTPClient calls other services, tracing and recording every call as a DownstreamCall
+	HTTPClient *http.Client
+	// Logger writes structured logs, use logger.FromContext in requests to link entries to their trace
+	Logger logger.Logger
 }
@@ -0,0 +1,193 @@
+/*
+Package httpclient provides the HTTP client features call other services with.
+
+Every attempt of a call is traced, carries the W3C trace context of the request so the callee joins
+the trace, and is recorded as a DownstreamCall in the ClientContext. Calls are bounded by a timeout
+per host and idempotent calls are retried with backoff when the callee is unreachable or overloaded.
+
+The timeout bounds each attempt, so a call retried until it gives up takes up to (retries + 1) × timeout
+plus the backoffs. The deadline of the request context bounds the whole call, retries included.
+*/
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"example/web-service-gin/app/appTracer"
+	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+// propagator writes the traceparent and tracestate headers
+var propagator = propagation.TraceContext{}
+
+// idempotentMethods are the methods that are safe to send again when an attempt fails
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// retryableStatuses are the responses telling the callee may answer the same call later
+var retryableStatuses = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+type transport struct {
+	next         http.RoundTripper
+	appTracer    appTracer.AppTracer
+	timeout      time.Duration
+	hostTimeouts map[string]time.Duration
+	retries      int
+	retryBackoff time.Duration
+}
+
+// New creates an http.Client sending its calls through NewTransport
+func New(cfg config.HTTPClientConfig, appTracer appTracer.AppTracer) *http.Client {
+	return &http.Client{Transport: NewTransport(cfg, appTracer, http.DefaultTransport)}
+}
+
+// NewTransport wraps next with tracing, trace context propagation, DownstreamCall recording,
+// per host timeouts and retries. Use it to instrument a client configured by a partner SDK.
+func NewTransport(cfg config.HTTPClientConfig, appTracer appTracer.AppTracer, next http.RoundTripper) http.RoundTripper {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	retryBackoff := cfg.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+	return &transport{
+		next:         next,
+		appTracer:    appTracer,
+		timeout:      timeout,
+		hostTimeouts: cfg.HostTimeouts,
+		retries:      max(cfg.Retries, 0),
+		retryBackoff: retryBackoff,
+	}
+}
+
+// RoundTrip sends the request, retrying idempotent requests whose body can be sent again
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if idempotentMethods[req.Method] && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil) {
+		retries = t.retries
+	}
+
+	backoff := t.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req, attempt)
+		retryable := err != nil || retryableStatuses[resp.StatusCode]
+		if !retryable || attempt >= retries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection goes back to the pool
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt sends the request once, bounded by the timeout of its host
+func (t *transport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	startTime := time.Now()
+	ctx, span := t.appTracer.CreateSpan(req.Context(), req.URL.Host)
+	ctx, cancel := context.WithTimeout(ctx, t.timeoutFor(req.URL.Hostname()))
+
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			span.End()
+			return nil, err
+		}
+		attemptReq.Body = body
+	}
+	propagator.Inject(trace.ContextWithSpan(ctx, span), propagation.HeaderCarrier(attemptReq.Header))
+
+	span.SetAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.Redacted()),
+		attribute.Int("http.attempt", attempt+1),
+	)
+
+	resp, err := t.next.RoundTrip(attemptReq)
+
+	call := clientContext.DownstreamCall{
+		ServiceTransaction: clientContext.ServiceTransaction{
+			ServiceName: req.URL.Host,
+			SpanId:      span.SpanContext().TraceID().String(),
+		},
+		ResponseTime: time.Since(startTime),
+		Error:        err,
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		cancel()
+		clientContext.AddDownstreamCall(req.Context(), call)
+		return nil, err
+	}
+
+	call.StatusCode = resp.StatusCode
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+	clientContext.AddDownstreamCall(req.Context(), call)
+
+	// The timeout covers reading the body, so it is only released once the caller closes it
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *transport) timeoutFor(host string) time.Duration {
+	if timeout, ok := t.hostTimeouts[host]; ok && timeout > 0 {
+		return timeout
+	}
+	return t.timeout
+}
+
+// cancelOnClose releases the context of an attempt when its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,164 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/config"
+	"example/web-service-gin/testUtils"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var testConfig = config.HTTPClientConfig{Timeout: time.Second, Retries: 2, RetryBackoff: time.Millisecond}
+
+// statusServer answers with the statuses in order, then with the last one
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1)) - 1
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(statuses[min(call, len(statuses)-1)])
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func get(t *testing.T, ctx context.Context, client *http.Client, url string) *http.Response {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestRecordsDownstreamCalls(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(testUtils.CreateTestContext(), spanContext)
+	client := New(testConfig, testUtils.NewAppTracer())
+
+	resp := get(t, ctx, client, server.URL)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "00-01000000000000000000000000000000-0200000000000000-01", traceparent)
+	calls := clientContext.GetClientContext(ctx).DownstreamCalls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, http.StatusCreated, calls[0].StatusCode)
+	assert.Equal(t, strings.TrimPrefix(server.URL, "http://"), calls[0].ServiceName)
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("Retries idempotent calls the callee could not answer", func(t *testing.T) {
+		server, calls := statusServer(t, http.StatusServiceUnavailable, http.StatusOK)
+		ctx := testUtils.CreateTestContext()
+
+		resp := get(t, ctx, New(testConfig, testUtils.NewAppTracer()), server.URL)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Len(t, clientContext.GetClientContext(ctx).DownstreamCalls(), 2)
+	})
+
+	t.Run("Returns the last response after the last retry", func(t *testing.T) {
+		server, calls := statusServer(t, http.StatusBadGateway)
+
+		resp := get(t, testUtils.CreateTestContext(), New(testConfig, testUtils.NewAppTracer()), server.URL)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Sends the body again", func(t *testing.T) {
+		var bodies []string
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		req, _ := http.NewRequestWithContext(testUtils.CreateTestContext(), http.MethodPut, server.URL, strings.NewReader(`{"price":9.99}`))
+		resp, err := New(testConfig, testUtils.NewAppTracer()).Do(req)
+
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, []string{`{"price":9.99}`, `{"price":9.99}`}, bodies)
+	})
+
+	t.Run("The deadline of the context bounds the retries", func(t *testing.T) {
+		server, calls := statusServer(t, http.StatusServiceUnavailable)
+		ctx, cancel := context.WithTimeout(testUtils.CreateTestContext(), 50*time.Millisecond)
+		defer cancel()
+		cfg := testConfig
+		cfg.Retries = 10
+		cfg.RetryBackoff = 40 * time.Millisecond
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		_, err := New(cfg, testUtils.NewAppTracer()).Do(req)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.LessOrEqual(t, calls.Load(), int32(2))
+	})
+
+	t.Run("Never retries calls that are not idempotent", func(t *testing.T) {
+		server, calls := statusServer(t, http.StatusServiceUnavailable)
+
+		req, _ := http.NewRequestWithContext(testUtils.CreateTestContext(), http.MethodPost, server.URL, strings.NewReader("{}"))
+		resp, err := New(testConfig, testUtils.NewAppTracer()).Do(req)
+
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestHostTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	cfg := config.HTTPClientConfig{
+		Timeout:      time.Minute,
+		HostTimeouts: map[string]time.Duration{serverURL.Hostname(): 20 * time.Millisecond},
+	}
+	ctx := testUtils.CreateTestContext()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	_, err := New(cfg, testUtils.NewAppTracer()).Do(req)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	calls := clientContext.GetClientContext(ctx).DownstreamCalls()
+	assert.Len(t, calls, 1)
+	assert.Error(t, calls[0].Error)
+}
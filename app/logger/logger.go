@@ -0,0 +1,142 @@
+/*
+Package logger writes the structured logs of the application.
+
+Entries are JSON, written to the configured output and sent through the OpenTelemetry log bridge, so
+they show up in uptrace next to the traces. A logger taken with FromContext, or WithContext, carries the
+trace and span of the request and the IP of its client, which links every entry to its trace.
+*/
+package logger
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/config"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fields are the structured data of an entry
+type Fields map[string]any
+
+// Logger writes structured log entries. The With methods return a Logger whose entries carry
+// the given fields, leaving the receiver unchanged.
+//
+// Example usage:
+//
+//	logger.FromContext(ctx).WithField("albumId", id).Warn("album has no tracks")
+type Logger interface {
+	WithField(key string, value any) Logger
+	WithFields(fields Fields) Logger
+	WithError(err error) Logger
+	// WithContext adds the trace and client of the request to the entries, see FromContext
+	WithContext(ctx context.Context) Logger
+
+	Debug(args ...any)
+	Info(args ...any)
+	Warn(args ...any)
+	Error(args ...any)
+	// Fatal logs the entry then exits the process
+	Fatal(args ...any)
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// defaultLogger is the Logger of FromContext, set by New
+var defaultLogger Logger = &logrusLogger{entry: logrus.NewEntry(logrus.StandardLogger())}
+
+// New configures the standard logrus logger from the configuration, adds the OpenTelemetry bridge
+// to it, naming the instrumentation after the app, and returns it as a Logger, which becomes the
+// default Logger. Packages still logging through logrus directly write in the same format and
+// reach uptrace as well.
+func New(cfg config.LogConfig, appName string) Logger {
+	standard := logrus.StandardLogger()
+	configure(standard, cfg, output(cfg))
+	standard.ReplaceHooks(logrus.LevelHooks{})
+	standard.AddHook(newOtelHook(appName))
+
+	defaultLogger = &logrusLogger{entry: logrus.NewEntry(standard)}
+	return defaultLogger
+}
+
+// NewWithOutput returns a Logger writing to out only, configured at the level of the configuration.
+// It leaves the default Logger unchanged, so tests can read what a component logs.
+func NewWithOutput(cfg config.LogConfig, out io.Writer) Logger {
+	logger := logrus.New()
+	configure(logger, cfg, out)
+	return &logrusLogger{entry: logrus.NewEntry(logger)}
+}
+
+// Default returns the Logger set by New, or one writing through the standard logrus logger as is
+func Default() Logger {
+	return defaultLogger
+}
+
+// FromContext returns the default Logger with the trace and client of the request, see WithContext
+func FromContext(ctx context.Context) Logger {
+	return defaultLogger.WithContext(ctx)
+}
+
+func configure(logger *logrus.Logger, cfg config.LogConfig, out io.Writer) {
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(out)
+
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		parsed, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			logger.WithField("level", cfg.Level).Warn("unknown log level, logging at info")
+		} else {
+			level = parsed
+		}
+	}
+	logger.SetLevel(level)
+}
+
+func output(cfg config.LogConfig) io.Writer {
+	if cfg.Output == "stderr" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func (l *logrusLogger) WithField(key string, value any) Logger {
+	return &logrusLogger{entry: l.entry.WithField(key, value)}
+}
+
+func (l *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logrusLogger) WithError(err error) Logger {
+	return &logrusLogger{entry: l.entry.WithError(err)}
+}
+
+// WithContext adds the traceId and spanId of the request, and its clientIp, from the ClientContext.
+// Outside of a request the trace and span come from the span of the context, when there is one.
+// The context is also handed to the OpenTelemetry bridge, which links the entry to the span.
+func (l *logrusLogger) WithContext(ctx context.Context) Logger {
+	fields := logrus.Fields{}
+	if currentContext, ok := clientContext.FromContext(ctx); ok && currentContext.TraceId != "" {
+		fields["traceId"] = currentContext.TraceId
+		fields["spanId"] = currentContext.SpanId
+		if currentContext.Client.IP != "" {
+			fields["clientIp"] = currentContext.Client.IP
+		}
+	} else if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields["traceId"] = spanContext.TraceID().String()
+		fields["spanId"] = spanContext.SpanID().String()
+	}
+	return &logrusLogger{entry: l.entry.WithContext(ctx).WithFields(fields)}
+}
+
+func (l *logrusLogger) Debug(args ...any) { l.entry.Debug(args...) }
+func (l *logrusLogger) Info(args ...any)  { l.entry.Info(args...) }
+func (l *logrusLogger) Warn(args ...any)  { l.entry.Warn(args...) }
+func (l *logrusLogger) Error(args ...any) { l.entry.Error(args...) }
+func (l *logrusLogger) Fatal(args ...any) { l.entry.Fatal(args...) }
@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"example/web-service-gin/config"
+	"example/web-service-gin/testUtils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/logtest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var logged []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		logged = append(logged, entry)
+	}
+	return logged
+}
+
+func TestNewWithOutput(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewWithOutput(config.LogConfig{Level: "warn"}, &buf)
+
+	log.Info("not logged")
+	log.WithField("albumId", "1").WithError(errors.New("boom")).Warn("logged")
+
+	logged := entries(t, &buf)
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "logged", logged[0]["msg"])
+	assert.Equal(t, "warning", logged[0]["level"])
+	assert.Equal(t, "1", logged[0]["albumId"])
+	assert.Equal(t, "boom", logged[0]["error"])
+}
+
+func TestWithContext(t *testing.T) {
+	t.Run("Adds the trace and client of the request", func(t *testing.T) {
+		var buf bytes.Buffer
+		NewWithOutput(config.LogConfig{}, &buf).WithContext(testUtils.CreateTestContext()).Info("in a request")
+
+		logged := entries(t, &buf)
+		assert.Equal(t, "test-trace-id", logged[0]["traceId"])
+		assert.Equal(t, "test-span-id", logged[0]["spanId"])
+		assert.Equal(t, "127.0.0.1", logged[0]["clientIp"])
+	})
+
+	t.Run("Adds the trace of the span outside of a request", func(t *testing.T) {
+		spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+		ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+		var buf bytes.Buffer
+		NewWithOutput(config.LogConfig{}, &buf).WithContext(ctx).Info("in the background")
+
+		logged := entries(t, &buf)
+		assert.Equal(t, spanContext.TraceID().String(), logged[0]["traceId"])
+		assert.Equal(t, spanContext.SpanID().String(), logged[0]["spanId"])
+		assert.NotContains(t, logged[0], "clientIp")
+	})
+
+	t.Run("Adds nothing without a trace", func(t *testing.T) {
+		var buf bytes.Buffer
+		NewWithOutput(config.LogConfig{}, &buf).WithContext(context.Background()).Info("no trace")
+
+		assert.NotContains(t, entries(t, &buf)[0], "traceId")
+	})
+}
+
+func TestOtelHook(t *testing.T) {
+	recorder := logtest.NewRecorder()
+	base := logrus.New()
+	base.SetOutput(io.Discard)
+	base.AddHook(&otelHook{logger: recorder.Logger("album-store")})
+	log := &logrusLogger{entry: logrus.NewEntry(base)}
+
+	log.WithFields(Fields{"albumId": "1", "count": 3, "data": map[string]int{"a": 1}}).Warn("bridged")
+
+	records := recorder.Result()[0].Records
+	assert.Len(t, records, 1)
+	assert.Equal(t, "bridged", records[0].Body().AsString())
+	assert.Equal(t, otellog.SeverityWarn, records[0].Severity())
+	assert.Equal(t, "warning", records[0].SeverityText())
+
+	attributes := map[string]otellog.Value{}
+	records[0].WalkAttributes(func(kv otellog.KeyValue) bool {
+		attributes[kv.Key] = kv.Value
+		return true
+	})
+	assert.Equal(t, "1", attributes["albumId"].AsString())
+	assert.Equal(t, int64(3), attributes["count"].AsInt64())
+	assert.Equal(t, `{"a":1}`, attributes["data"].AsString())
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// severities maps the logrus levels to OpenTelemetry severities
+var severities = map[logrus.Level]otellog.Severity{
+	logrus.TraceLevel: otellog.SeverityTrace,
+	logrus.DebugLevel: otellog.SeverityDebug,
+	logrus.InfoLevel:  otellog.SeverityInfo,
+	logrus.WarnLevel:  otellog.SeverityWarn,
+	logrus.ErrorLevel: otellog.SeverityError,
+	logrus.FatalLevel: otellog.SeverityFatal,
+	logrus.PanicLevel: otellog.SeverityFatal4,
+}
+
+// otelHook is the OpenTelemetry log bridge: it emits every logrus entry as a log record through the
+// global LoggerProvider, which uptrace exports. Records emitted with the context of a span are
+// linked to it by the provider.
+type otelHook struct {
+	logger otellog.Logger
+}
+
+// newOtelHook takes its logger from the global LoggerProvider, which forwards to the provider
+// uptrace sets even when the hook is created first
+func newOtelHook(name string) *otelHook {
+	return &otelHook{logger: global.GetLoggerProvider().Logger(name)}
+}
+
+func (h *otelHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *otelHook) Fire(entry *logrus.Entry) error {
+	var record otellog.Record
+	record.SetTimestamp(entry.Time)
+	record.SetBody(otellog.StringValue(entry.Message))
+	record.SetSeverity(severities[entry.Level])
+	record.SetSeverityText(entry.Level.String())
+	for key, value := range entry.Data {
+		record.AddAttributes(otellog.KeyValue{Key: key, Value: attributeValue(value)})
+	}
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	h.logger.Emit(ctx, record)
+	return nil
+}
+
+// attributeValue converts a field to a record value. Values without a matching kind, such as
+// the ClientContext of the access log, are sent as their JSON.
+func attributeValue(value any) otellog.Value {
+	switch value := value.(type) {
+	case string:
+		return otellog.StringValue(value)
+	case bool:
+		return otellog.BoolValue(value)
+	case int:
+		return otellog.IntValue(value)
+	case int64:
+		return otellog.Int64Value(value)
+	case float64:
+		return otellog.Float64Value(value)
+	case time.Duration:
+		return otellog.StringValue(value.String())
+	case error:
+		return otellog.StringValue(value.Error())
+	case fmt.Stringer:
+		return otellog.StringValue(value.String())
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return otellog.StringValue(fmt.Sprint(value))
+	}
+	return otellog.StringValue(string(data))
+}
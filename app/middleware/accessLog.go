@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"example/web-service-gin/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultMaxBodySize            = 4096
+	defaultRepeatedQueryThreshold = 5
+
+	redacted = "[REDACTED]"
+	// unparsedBody replaces a JSON body that can't be parsed, and so can't be redacted
+	unparsedBody = "[body not logged: it could not be parsed to redact it]"
+)
+
+// defaultRedactFields are always redacted, whatever the configuration adds
+var defaultRedactFields = []string{"password", "token", "secret", "authorization", "cookie"}
+
+// accessLog holds the access log configuration, resolved once when the middleware is created
+type accessLog struct {
+	sampleRate             float64
+	routeSampleRates       map[string]float64
+	skipPaths              map[string]bool
+	headers                []string
+	requestBody            bool
+	responseBody           bool
+	maxBodySize            int
+	redactFields           []string
+	repeatedQueryThreshold int
+}
+
+func newAccessLog(cfg config.AccessLogConfig) *accessLog {
+	a := &accessLog{
+		sampleRate:             1,
+		routeSampleRates:       map[string]float64{},
+		skipPaths:              map[string]bool{},
+		headers:                cfg.Headers,
+		requestBody:            cfg.RequestBody,
+		responseBody:           cfg.ResponseBody,
+		maxBodySize:            cfg.MaxBodySize,
+		repeatedQueryThreshold: cfg.RepeatedQueryThreshold,
+	}
+	if cfg.SampleRate != nil {
+		a.sampleRate = *cfg.SampleRate
+	}
+	for _, route := range cfg.Routes {
+		a.routeSampleRates[route.Route] = route.SampleRate
+	}
+	for _, path := range cfg.SkipPaths {
+		a.skipPaths[path] = true
+	}
+	if a.maxBodySize <= 0 {
+		a.maxBodySize = defaultMaxBodySize
+	}
+	if a.repeatedQueryThreshold == 0 {
+		a.repeatedQueryThreshold = defaultRepeatedQueryThreshold
+	}
+	for _, field := range append(defaultRedactFields, cfg.RedactFields...) {
+		a.redactFields = append(a.redactFields, strings.ToLower(field))
+	}
+	return a
+}
+
+func (a *accessLog) skip(c *gin.Context) bool {
+	return a.skipPaths[c.Request.URL.Path] || a.skipPaths[c.FullPath()]
+}
+
+// sampled draws whether a request of the route is logged
+func (a *accessLog) sampled(route string) bool {
+	rate, ok := a.routeSampleRates[route]
+	if !ok {
+		rate = a.sampleRate
+	}
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// redacts tells whether a header or field of that name holds a value that must not be logged
+func (a *accessLog) redacts(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range a.redactFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHeaders returns the allowed headers of the request, with the sensitive ones redacted
+func (a *accessLog) requestHeaders(header http.Header) map[string]string {
+	headers := map[string]string{}
+	for _, name := range a.headers {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if a.redacts(name) {
+			headers[name] = redacted
+		} else {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
+
+// loggedBody returns what is logged of a captured body: JSON and form bodies with their sensitive fields
+// redacted, text as is, and only the size and type of anything else
+func (a *accessLog) loggedBody(body *capturedBody, contentType string) any {
+	if body == nil || len(body.data) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var value any
+		if body.truncated || json.Unmarshal(body.data, &value) != nil {
+			return unparsedBody
+		}
+		return a.redactJSON(value)
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body.data))
+		if err != nil {
+			return unparsedBody
+		}
+		for name := range values {
+			if a.redacts(name) {
+				values[name] = []string{redacted}
+			}
+		}
+		return values.Encode()
+	case strings.HasPrefix(mediaType, "text/"):
+		return string(body.data)
+	default:
+		return fmt.Sprintf("[%d bytes of %s]", len(body.data), contentType)
+	}
+}
+
+func (a *accessLog) redactJSON(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, field := range value {
+			if a.redacts(name) {
+				value[name] = redacted
+			} else {
+				value[name] = a.redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = a.redactJSON(item)
+		}
+	}
+	return value
+}
+
+// capturedBody is the start of a body, up to the size cap
+type capturedBody struct {
+	data      []byte
+	truncated bool
+}
+
+func (b *capturedBody) capture(data []byte, maxSize int) {
+	room := maxSize - len(b.data)
+	if len(data) > room {
+		b.truncated = true
+		data = data[:max(room, 0)]
+	}
+	b.data = append(b.data, data...)
+}
+
+// captureRequestBody reads the start of the request body and puts it back in front of the rest,
+// so the handler reads the whole body while at most maxSize bytes are held for the log
+func captureRequestBody(req *http.Request, maxSize int) *capturedBody {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	head, _ := io.ReadAll(io.LimitReader(req.Body, int64(maxSize)+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+
+	body := &capturedBody{}
+	body.capture(head, maxSize)
+	return body
+}
+
+// responseCapture keeps the start of the response body written through it
+type responseCapture struct {
+	gin.ResponseWriter
+	body    capturedBody
+	maxSize int
+}
+
+func (w *responseCapture) Write(data []byte) (int, error) {
+	w.body.capture(data, w.maxSize)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.capture([]byte(s), w.maxSize)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example/web-service-gin/app/apiErrors"
+	"example/web-service-gin/app/logger"
+	"example/web-service-gin/config"
+	"example/web-service-gin/testUtils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveLogged serves the request through JsonLoggerWithConfig and ErrorHandler, registered as the server does,
+// and returns the lines it logged
+func serveLogged(t *testing.T, cfg config.AccessLogConfig, method string, route string, handler gin.HandlerFunc, req *http.Request) []map[string]any {
+	var buf bytes.Buffer
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(testUtils.CreateTestContext())
+	})
+	router.Use(JsonLoggerWithConfig(cfg, logger.NewWithOutput(config.LogConfig{}, &buf)))
+	router.Use(ErrorHandler)
+	router.Handle(method, route, handler)
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJsonLoggerWithConfig(t *testing.T) {
+	t.Run("Logs the allowed headers and the bodies redacted", func(t *testing.T) {
+		cfg := config.AccessLogConfig{
+			Headers:      []string{"User-Agent", "Authorization", "X-Missing"},
+			RequestBody:  true,
+			ResponseBody: true,
+		}
+		var received string
+		handler := func(c *gin.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			received = string(body)
+			c.JSON(http.StatusCreated, gin.H{"id": "1", "apiToken": "abc"})
+		}
+		body := `{"user":"jo","password":"hunter2","nested":[{"client_secret":"s"}]}`
+		req, _ := http.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "test-agent")
+		req.Header.Set("Authorization", "Bearer abc")
+
+		entries := serveLogged(t, cfg, http.MethodPost, "/login", handler, req)
+
+		assert.Equal(t, body, received)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, map[string]any{"User-Agent": "test-agent", "Authorization": redacted}, entries[0]["requestHeaders"])
+		assert.Equal(t, map[string]any{
+			"user":     "jo",
+			"password": redacted,
+			"nested":   []any{map[string]any{"client_secret": redacted}},
+		}, entries[0]["requestBody"])
+		assert.Equal(t, map[string]any{"id": "1", "apiToken": redacted}, entries[0]["responseBody"])
+	})
+
+	t.Run("Doesn't log requests left out by sampling unless they fail", func(t *testing.T) {
+		none := 0.0
+		cfg := config.AccessLogConfig{
+			SampleRate: &none,
+			Routes:     []config.RouteLogConfig{{Route: "/sampled", SampleRate: 1}},
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/ok", nil)
+		assert.Empty(t, serveLogged(t, cfg, http.MethodGet, "/ok", func(c *gin.Context) { c.Status(http.StatusOK) }, req))
+
+		req, _ = http.NewRequest(http.MethodGet, "/sampled", nil)
+		assert.Len(t, serveLogged(t, cfg, http.MethodGet, "/sampled", func(c *gin.Context) { c.Status(http.StatusOK) }, req), 1)
+
+		req, _ = http.NewRequest(http.MethodGet, "/fail", nil)
+		entries := serveLogged(t, cfg, http.MethodGet, "/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) }, req)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "error", entries[0]["level"])
+	})
+
+	t.Run("Logs the errors ErrorHandler responds with whatever the sample rate", func(t *testing.T) {
+		none := 0.0
+		failing := func(c *gin.Context) {
+			c.Error(apiErrors.NewGenericError("database unavailable"))
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/albums", nil)
+		entries := serveLogged(t, config.AccessLogConfig{SampleRate: &none}, http.MethodGet, "/albums", failing, req)
+
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "error", entries[0]["level"])
+		response := entries[0]["clientContext"].(map[string]any)["Response"].(map[string]any)
+		assert.Equal(t, float64(http.StatusInternalServerError), response["Status"])
+
+		req, _ = http.NewRequest(http.MethodGet, "/albums", nil)
+		entries = serveLogged(t, config.AccessLogConfig{ResponseBody: true}, http.MethodGet, "/albums", failing, req)
+
+		assert.Len(t, entries, 1)
+		assert.Equal(t, map[string]any{"error": map[string]any{
+			"code":    "INTERNAL_SERVER_ERROR",
+			"message": "database unavailable",
+		}}, entries[0]["responseBody"])
+	})
+
+	t.Run("Skips the configured paths", func(t *testing.T) {
+		cfg := config.AccessLogConfig{SkipPaths: []string{"/health"}}
+		req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+
+		assert.Empty(t, serveLogged(t, cfg, http.MethodGet, "/health", func(c *gin.Context) { c.Status(http.StatusOK) }, req))
+	})
+
+	t.Run("Logs the trace of the request", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/ok", nil)
+
+		entries := serveLogged(t, config.AccessLogConfig{}, http.MethodGet, "/ok", func(c *gin.Context) { c.Status(http.StatusOK) }, req)
+
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "test-trace-id", entries[0]["traceId"])
+		assert.Equal(t, "127.0.0.1", entries[0]["clientIp"])
+	})
+}
+
+func TestCaptureRequestBody(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+
+	body := captureRequestBody(req, 4)
+
+	assert.Equal(t, "0123", string(body.data))
+	assert.True(t, body.truncated)
+	rest, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "0123456789", string(rest))
+}
+
+func TestLoggedBody(t *testing.T) {
+	a := newAccessLog(config.AccessLogConfig{RedactFields: []string{"ssn"}})
+
+	t.Run("Redacts form fields", func(t *testing.T) {
+		body := &capturedBody{data: []byte("name=jo&ssn=123&new_password=x")}
+		assert.Equal(t, "name=jo&new_password=%5BREDACTED%5D&ssn=%5BREDACTED%5D", a.loggedBody(body, "application/x-www-form-urlencoded"))
+	})
+
+	t.Run("Doesn't log JSON it can't redact", func(t *testing.T) {
+		body := &capturedBody{data: []byte(`{"password":"hun`), truncated: true}
+		assert.Equal(t, unparsedBody, a.loggedBody(body, "application/json; charset=utf-8"))
+	})
+
+	t.Run("Logs text as is and only the size of anything else", func(t *testing.T) {
+		assert.Equal(t, "hello", a.loggedBody(&capturedBody{data: []byte("hello")}, "text/plain"))
+		assert.Equal(t, "[3 bytes of image/png]", a.loggedBody(&capturedBody{data: []byte{1, 2, 3}}, "image/png"))
+		assert.Nil(t, a.loggedBody(nil, "application/json"))
+	})
+}
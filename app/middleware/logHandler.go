@@ -1,31 +1,48 @@
 package middleware
 
 import (
-	"bytes"
 	"example/web-service-gin/app/clientContext"
-	"io"
+	"example/web-service-gin/app/logger"
+	"example/web-service-gin/config"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
+// JsonLogger logs every request to the default Logger with the default access log configuration,
+// see JsonLoggerWithConfig
 func JsonLogger() gin.HandlerFunc {
-	logrus.SetFormatter(&logrus.JSONFormatter{})
-	logrus.SetLevel(logrus.DebugLevel)
-	logrus.SetOutput(os.Stdout)
-	logrus.SetReportCaller(true)
+	return JsonLoggerWithConfig(config.AccessLogConfig{}, logger.Default())
+}
+
+// JsonLoggerWithConfig logs the sampled requests with their ClientContext, a summary of the cost of
+// their calls and, when configured, their headers and bodies. Entries carry the trace of the request.
+//
+// Failed requests are logged whatever the sample rate. Requests running the same query
+// RepeatedQueryThreshold times or more, a likely N+1, are also logged as a warning with their route.
+//
+// Register it before ErrorHandler: the status and body of an error response are only written once
+// ErrorHandler returns, so a logger registered after it sees the 200 the handler left.
+func JsonLoggerWithConfig(cfg config.AccessLogConfig, log logger.Logger) gin.HandlerFunc {
+	accessLog := newAccessLog(cfg)
 
 	return func(c *gin.Context) {
+		if accessLog.skip(c) {
+			c.Next()
+			return
+		}
 		startTime := time.Now()
+		sampled := accessLog.sampled(c.FullPath())
 
-		var requestBody []byte
-		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)
-			// Restore the original body to avoid affecting subsequent handlers
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		var requestBody *capturedBody
+		if sampled && accessLog.requestBody {
+			requestBody = captureRequestBody(c.Request, accessLog.maxBodySize)
+		}
+		var response *responseCapture
+		if sampled && accessLog.responseBody {
+			response = &responseCapture{ResponseWriter: c.Writer, maxSize: accessLog.maxBodySize}
+			c.Writer = response
 		}
 
 		// Process the users request
@@ -38,17 +55,56 @@ func JsonLogger() gin.HandlerFunc {
 			Status: writer.Status(),
 		})
 		clientContext.AddResponseTime(c.Request.Context(), responseTime)
-		currentContext := clientContext.GetClientContext(c.Request.Context())
+		currentContext, ok := clientContext.FromContext(c.Request.Context())
+		if !ok {
+			return
+		}
+		// Goroutines started by the handler may still be recording calls, so log a copy
+		snapshot := currentContext.Snapshot()
 
-		level := logrus.InfoLevel
-		if currentContext.Response.Status >= http.StatusInternalServerError {
-			level = logrus.ErrorLevel
+		failed := snapshot.Response.Status >= http.StatusInternalServerError
+		summary := snapshot.Summary(accessLog.repeatedQueryThreshold)
+		requestLog := log.WithContext(c.Request.Context())
+
+		if sampled || failed {
+			fields := logger.Fields{
+				"clientContext": snapshot,
+				"summary":       summary,
+			}
+			if headers := accessLog.requestHeaders(c.Request.Header); len(headers) > 0 {
+				fields["requestHeaders"] = headers
+			}
+			if body := accessLog.loggedBody(requestBody, c.ContentType()); body != nil {
+				fields["requestBody"] = body
+			}
+			if response != nil {
+				if body := accessLog.loggedBody(&response.body, writer.Header().Get("Content-Type")); body != nil {
+					fields["responseBody"] = body
+				}
+			}
+
+			if failed {
+				requestLog.WithFields(fields).Error("Request logged")
+			} else {
+				requestLog.WithFields(fields).Info("Request logged")
+			}
 		}
 
-		// Log the entry as JSON
-		logrus.WithFields(logrus.Fields{
-			"clientContext": *currentContext,
-		}).Log(level, "Request logged")
+		if len(summary.RepeatedQueries) > 0 {
+			requestLog.WithFields(logger.Fields{
+				"route":           routeOf(c),
+				"repeatedQueries": summary.RepeatedQueries,
+			}).Warn("Request repeated queries, likely an N+1")
+		}
 	}
+}
 
+// routeOf returns the route pattern of the request, such as "/albums/:id", so warnings of the
+// same route can be grouped, or the path when no route matched
+func routeOf(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return c.Request.Method + " " + route
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/app/logger"
+	"example/web-service-gin/config"
 	"example/web-service-gin/testUtils"
 	"net/http"
 	"net/http/httptest"
@@ -42,7 +44,7 @@ func TestJsonLogger(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		var logEntry struct {
-			ClientContext clientContext.ClientContext `json:"clientContext"`
+			ClientContext clientContext.Snapshot `json:"clientContext"`
 		}
 		err := json.Unmarshal(buf.Bytes(), &logEntry)
 		assert.NoError(t, err)
@@ -75,7 +77,7 @@ func TestJsonLogger(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 
 		var logEntry struct {
-			ClientContext clientContext.ClientContext `json:"clientContext"`
+			ClientContext clientContext.Snapshot `json:"clientContext"`
 		}
 		err := json.Unmarshal(buf.Bytes(), &logEntry)
 		assert.NoError(t, err)
@@ -103,7 +105,7 @@ func TestJsonLogger(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 
 		var logEntry struct {
-			ClientContext clientContext.ClientContext `json:"clientContext"`
+			ClientContext clientContext.Snapshot `json:"clientContext"`
 		}
 		err := json.Unmarshal(buf.Bytes(), &logEntry)
 		assert.NoError(t, err)
@@ -160,3 +162,45 @@ func TestJsonLoggerLevels(t *testing.T) {
 		// assert.NoError(t, err)
 	})
 }
+
+func TestJsonLoggerRepeatedQueries(t *testing.T) {
+	var buf bytes.Buffer
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(testUtils.CreateTestContext())
+	})
+	router.Use(JsonLoggerWithConfig(config.AccessLogConfig{}, logger.NewWithOutput(config.LogConfig{}, &buf)))
+
+	router.GET("/albums/:id", func(c *gin.Context) {
+		for i := 0; i < defaultRepeatedQueryThreshold; i++ {
+			clientContext.AddDatabaseCall(c.Request.Context(), clientContext.DatabaseCall{Query: "SELECT * FROM tracks WHERE album_id = $1"})
+		}
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/albums/1", nil)
+	router.ServeHTTP(w, req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var entry struct {
+		Summary clientContext.Summary `json:"summary"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, defaultRepeatedQueryThreshold, entry.Summary.Database.Count)
+
+	var warning struct {
+		Level           string                        `json:"level"`
+		Route           string                        `json:"route"`
+		TraceId         string                        `json:"traceId"`
+		RepeatedQueries []clientContext.RepeatedQuery `json:"repeatedQueries"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &warning))
+	assert.Equal(t, "warning", warning.Level)
+	assert.Equal(t, "GET /albums/:id", warning.Route)
+	assert.Equal(t, "test-trace-id", warning.TraceId)
+	assert.Equal(t, []clientContext.RepeatedQuery{{Query: "SELECT * FROM tracks WHERE album_id = $1", Count: defaultRepeatedQueryThreshold}}, warning.RepeatedQueries)
+}
@@ -0,0 +1,135 @@
+/*
+Pagination parses page and cursor query parameters and writes RFC 8288 Link headers for paginated responses.
+*/
+package pagination
+
+import (
+	"example/web-service-gin/app/apiErrors"
+	"example/web-service-gin/app/db"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPage  = 1
+	DefaultLimit = 10
+	MaxLimit     = 100
+
+	pageQueryKey   = "page"
+	limitQueryKey  = "limit"
+	cursorQueryKey = "cursor"
+)
+
+// Params is the validated page and page size requested by the client.
+// When Cursor is set the client is walking the list in keyset mode and Page is not used.
+type Params struct {
+	Page   int
+	Limit  int
+	Cursor string
+}
+
+// ParseQuery reads ?page=, ?limit= and ?cursor= from the request.
+// Missing values fall back to DefaultPage and DefaultLimit.
+// A page below 1, a limit below 1, a limit above MaxLimit or a page combined with a cursor is a bad request.
+// The cursor is returned as is, decoding it is left to the feature that knows its key type.
+func ParseQuery(c *gin.Context) (Params, error) {
+	cursor := c.Query(cursorQueryKey)
+	if _, hasPage := c.GetQuery(pageQueryKey); hasPage && cursor != "" {
+		return Params{}, apiErrors.NewBadRequestError(fmt.Sprintf("%s cannot be combined with %s", pageQueryKey, cursorQueryKey))
+	}
+	page, err := parsePositiveInt(c, pageQueryKey, DefaultPage)
+	if err != nil {
+		return Params{}, err
+	}
+	limit, err := parsePositiveInt(c, limitQueryKey, DefaultLimit)
+	if err != nil {
+		return Params{}, err
+	}
+	if limit > MaxLimit {
+		return Params{}, apiErrors.NewBadRequestError(fmt.Sprintf("%s must not be greater than %d", limitQueryKey, MaxLimit))
+	}
+	if cursor != "" {
+		return Params{Limit: limit, Cursor: cursor}, nil
+	}
+	return Params{Page: page, Limit: limit}, nil
+}
+
+func parsePositiveInt(c *gin.Context, key string, defaultValue int) (int, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, apiErrors.NewBadRequestError(fmt.Sprintf("%s must be a positive integer", key))
+	}
+	return value, nil
+}
+
+// SetLinkHeader writes first, prev, next and last links for the page.
+// Keyset pages only get prev and next links built from their cursors.
+// The links keep every other query parameter of the current request.
+func SetLinkHeader[T any](c *gin.Context, page *db.Paginated[T]) {
+	if page == nil {
+		return
+	}
+	if page.Page == 0 {
+		setCursorLinkHeader(c, page)
+		return
+	}
+	if page.TotalPages == 0 {
+		return
+	}
+
+	links := []string{link(c.Request.URL, 1, page.Limit, "first")}
+	if page.Page > 1 {
+		links = append(links, link(c.Request.URL, min(page.Page-1, page.TotalPages), page.Limit, "prev"))
+	}
+	if page.HasNext {
+		links = append(links, link(c.Request.URL, page.Page+1, page.Limit, "next"))
+	}
+	links = append(links, link(c.Request.URL, page.TotalPages, page.Limit, "last"))
+
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+func link(current *url.URL, page int, limit int, rel string) string {
+	target := *current
+	query := target.Query()
+	query.Set(pageQueryKey, strconv.Itoa(page))
+	query.Set(limitQueryKey, strconv.Itoa(limit))
+	target.RawQuery = query.Encode()
+	target.Scheme = ""
+	target.Host = ""
+	return fmt.Sprintf("<%s>; rel=\"%s\"", target.String(), rel)
+}
+
+func setCursorLinkHeader[T any](c *gin.Context, page *db.Paginated[T]) {
+	if page.NextCursor == "" && page.PrevCursor == "" {
+		return
+	}
+	links := []string{}
+	if page.PrevCursor != "" {
+		links = append(links, cursorLink(c.Request.URL, page.PrevCursor, page.Limit, "prev"))
+	}
+	if page.NextCursor != "" {
+		links = append(links, cursorLink(c.Request.URL, page.NextCursor, page.Limit, "next"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+func cursorLink(current *url.URL, cursor string, limit int, rel string) string {
+	target := *current
+	query := target.Query()
+	query.Del(pageQueryKey)
+	query.Set(cursorQueryKey, cursor)
+	query.Set(limitQueryKey, strconv.Itoa(limit))
+	target.RawQuery = query.Encode()
+	target.Scheme = ""
+	target.Host = ""
+	return fmt.Sprintf("<%s>; rel=\"%s\"", target.String(), rel)
+}
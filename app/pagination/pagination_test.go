@@ -0,0 +1,87 @@
+package pagination
+
+import (
+	"example/web-service-gin/app/apiErrors"
+	"example/web-service-gin/app/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func createContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, target, nil)
+	return c, w
+}
+
+func TestParseQuery(t *testing.T) {
+
+	t.Run("Defaults", func(t *testing.T) {
+		c, _ := createContext("/items")
+		params, err := ParseQuery(c)
+		assert.NoError(t, err)
+		assert.Equal(t, Params{Page: DefaultPage, Limit: DefaultLimit}, params)
+	})
+
+	t.Run("Values from the query", func(t *testing.T) {
+		c, _ := createContext("/items?page=3&limit=25")
+		params, err := ParseQuery(c)
+		assert.NoError(t, err)
+		assert.Equal(t, Params{Page: 3, Limit: 25}, params)
+	})
+
+	t.Run("Cursor from the query", func(t *testing.T) {
+		c, _ := createContext("/items?cursor=abc.def&limit=5")
+		params, err := ParseQuery(c)
+		assert.NoError(t, err)
+		assert.Equal(t, Params{Limit: 5, Cursor: "abc.def"}, params)
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		for _, target := range []string{"/items?page=0", "/items?page=abc", "/items?limit=-1", "/items?limit=101", "/items?page=2&cursor=abc.def"} {
+			c, _ := createContext(target)
+			_, err := ParseQuery(c)
+			var apiErr *apiErrors.APIError
+			assert.ErrorAs(t, err, &apiErr, target)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Status, target)
+		}
+	})
+}
+
+func TestSetLinkHeader(t *testing.T) {
+
+	t.Run("First page keeps other query parameters", func(t *testing.T) {
+		c, w := createContext("/items?artist=Miles+Davis")
+		SetLinkHeader(c, db.NewPaginated([]int{1, 2}, 5, 1, 2))
+		assert.Equal(t,
+			`</items?artist=Miles+Davis&limit=2&page=1>; rel="first", </items?artist=Miles+Davis&limit=2&page=2>; rel="next", </items?artist=Miles+Davis&limit=2&page=3>; rel="last"`,
+			w.Header().Get("Link"))
+	})
+
+	t.Run("Last page has no next link", func(t *testing.T) {
+		c, w := createContext("/items?page=3&limit=2")
+		SetLinkHeader(c, db.NewPaginated([]int{5}, 5, 3, 2))
+		assert.Equal(t,
+			`</items?limit=2&page=1>; rel="first", </items?limit=2&page=2>; rel="prev", </items?limit=2&page=3>; rel="last"`,
+			w.Header().Get("Link"))
+	})
+
+	t.Run("Keyset page links to its cursors", func(t *testing.T) {
+		c, w := createContext("/items?cursor=current&limit=2")
+		SetLinkHeader(c, &db.Paginated[int]{Items: []int{3, 4}, Limit: 2, HasNext: true, NextCursor: "next", PrevCursor: "prev"})
+		assert.Equal(t,
+			`</items?cursor=prev&limit=2>; rel="prev", </items?cursor=next&limit=2>; rel="next"`,
+			w.Header().Get("Link"))
+	})
+
+	t.Run("Empty result has no links", func(t *testing.T) {
+		c, w := createContext("/items")
+		SetLinkHeader(c, db.NewPaginated([]int{}, 0, 1, 10))
+		assert.Equal(t, "", w.Header().Get("Link"))
+	})
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"example/web-service-gin/app/appTracer"
 	"example/web-service-gin/app/cache"
+	"example/web-service-gin/app/clientContext"
 	"example/web-service-gin/app/db"
 	"example/web-service-gin/app/dependencies"
+	"example/web-service-gin/app/httpclient"
+	"example/web-service-gin/app/logger"
 	"example/web-service-gin/app/middleware"
 	"example/web-service-gin/config"
+	"example/web-service-gin/migrations"
 	"fmt"
 	"net/http"
 	"os"
@@ -16,7 +20,6 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 	"github.com/uptrace/uptrace-go/uptrace"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
@@ -36,11 +39,12 @@ type RouterFunc func(dependencies *dependencies.Dependencies)
 //
 // Parameters:
 //   - srv: A pointer to the http.Server that should be gracefully shut down.
+//   - log: The Logger the shutdown is logged with.
 //
 // The function blocks until the shutdown is complete or the timeout is reached.
 // It logs the shutdown process and any errors that occur during shutdown.
 
-func initGracefulShutdown(srv *http.Server) {
+func initGracefulShutdown(srv *http.Server, log logger.Logger) {
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
@@ -51,29 +55,31 @@ func initGracefulShutdown(srv *http.Server) {
 	// kill -9 is syscall. SIGKILL but can"t be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	logrus.Info("Shutdown Server ...")
+	log.Info("Shutdown Server ...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), gracefulShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		logrus.Fatal("Server Shutdown:", err)
+		log.WithError(err).Fatal("Server Shutdown")
 	}
 	// catching ctx.Done(). timeout of 5 seconds.
 	select {
 	case <-ctx.Done():
-		logrus.Info("timeout of 5 seconds.")
+		log.Info("timeout of 5 seconds.")
 	}
-	logrus.Info("Server exiting")
+	log.Info("Server exiting")
 }
 
 // ServerParams is a struct that contains all the dependencies needed to run the server
 // if ServerParams.Dependencies is nil, it will be initialized with default values
 //   - DB is postgres
-//   - Cache is redis
+//   - Cache is selected by cache.driver, redis by default
 //   - Router is gin.Default()
 type ServerParams struct {
 	Routes       RouterFunc
 	Dependencies *dependencies.Dependencies
+	// RequireMigrations refuses to start the server while the database has pending migrations
+	RequireMigrations bool
 }
 
 func RunServer(ServerParams ServerParams) {
@@ -83,31 +89,48 @@ func RunServer(ServerParams ServerParams) {
 		panic(err)
 	}
 	configFile := config.GetConfig()
+	db.SetCursorSecret(configFile.Pagination.CursorSecret)
+	cache.SetKeyPrefix(configFile.AppName)
 
-	// Initialize Redis client
+	// Initialize the cache client
 	appTracer := appTracer.NewAppTracer(configFile)
 	defer uptrace.Shutdown(context.Background())
-	redisClient := cache.NewCacher(configFile.Redis, appTracer)
+	log := logger.New(configFile.Log, configFile.AppName)
+	cacher, err := cache.NewCacherFromConfig(configFile, appTracer)
+	if err != nil {
+		panic(fmt.Errorf("failed to create cache: %w", err))
+	}
+	cacheStatus, _ := cacher.(cache.StatusReporter)
+	invalidations := cache.NewInvalidationBus(cacher, appTracer)
+	defer invalidations.Close()
 
 	// Initialize database connection
 	dbConn, err := db.NewDatabase(configFile.DB, appTracer)
 	if err != nil {
 		panic(fmt.Errorf("failed to connect to database: %w", err))
 	}
+	if ServerParams.RequireMigrations {
+		requireMigrations(dbConn)
+	}
 
 	router := gin.Default()
 	router.Use(otelgin.Middleware(configFile.AppName))
 	router.Use(middleware.ClientContextMiddleware())
 	router.Use(middleware.TraceMiddleware(configFile.AppName))
+	// The access log wraps ErrorHandler so it logs the error responses ErrorHandler writes
+	router.Use(middleware.JsonLoggerWithConfig(configFile.Log.AccessLog, log))
 	router.Use(middleware.ErrorHandler)
-	router.Use(middleware.JsonLogger())
 
 	var serverDependencies *dependencies.Dependencies
 	if ServerParams.Dependencies == nil {
 		serverDependencies = &dependencies.Dependencies{
-			Cache:  redisClient,
-			DB:     dbConn,
-			Router: router,
+			Cache:         cacher,
+			CacheStatus:   cacheStatus,
+			Invalidations: invalidations,
+			DB:            dbConn,
+			Router:        router,
+			HTTPClient:    httpclient.New(configFile.HTTPClient, appTracer),
+			Logger:        log,
 		}
 	} else {
 		serverDependencies = ServerParams.Dependencies
@@ -126,5 +149,22 @@ func RunServer(ServerParams ServerParams) {
 		}
 	}()
 
-	initGracefulShutdown(srv)
+	initGracefulShutdown(srv, log)
+}
+
+// requireMigrations panics when the schema is behind the migrations embedded in the binary,
+// so a server never runs queries against a schema it doesn't expect
+func requireMigrations(dbConn db.Database) {
+	migrator, err := migrations.NewMigrator(dbConn)
+	if err != nil {
+		panic(fmt.Errorf("failed to load migrations: %w", err))
+	}
+	ctx := context.WithValue(context.Background(), clientContext.ClientContextKey, &clientContext.ClientContext{})
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		panic(fmt.Errorf("failed to check migrations: %w", err))
+	}
+	if len(pending) > 0 {
+		panic(fmt.Errorf("%d pending migrations, run `go run main.go migrate up` before starting the server", len(pending)))
+	}
 }
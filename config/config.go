@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -17,6 +18,30 @@ type RedisClientConfig struct {
 	Port     int    `mapstructure:"port"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
+
+	// Timeouts default to the go-redis ones when not set
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// CacheConfig selects the Cacher the server runs with
+type CacheConfig struct {
+	// Driver is one of "redis", "memory" or "tiered". Defaults to "redis"
+	Driver string `mapstructure:"driver"`
+	// MaxEntries bounds the in-process cache used by the memory and tiered drivers
+	MaxEntries int `mapstructure:"max_entries"`
+	// LocalTTL is how long the tiered driver keeps a value in process before reading it from redis again
+	LocalTTL time.Duration `mapstructure:"local_ttl"`
+	// Breaker stops calling redis while it is failing
+	Breaker BreakerConfig `mapstructure:"breaker"`
+}
+
+type BreakerConfig struct {
+	// Failures is the number of consecutive failures that opens the breaker
+	Failures int `mapstructure:"failures"`
+	// ProbeInterval is how often an open breaker checks whether the cache recovered
+	ProbeInterval time.Duration `mapstructure:"probe_interval"`
 }
 
 type DatabaseConfig struct {
@@ -28,6 +53,80 @@ type DatabaseConfig struct {
 
 	DBName  string `mapstructure:"dbname"`
 	SSLMode string `mapstructure:"sslmode"`
+
+	// Connection pool limits. Zero keeps the database/sql default
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+
+	// ConnectTimeout bounds every attempt to reach the database at startup
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	// ConnectRetries is how many times connecting is retried before giving up
+	ConnectRetries int `mapstructure:"connect_retries"`
+	// ConnectBackoff is the wait before the first retry, doubled after every attempt
+	ConnectBackoff time.Duration `mapstructure:"connect_backoff"`
+
+	// Replicas serve the reads made outside of transactions. They share the credentials of the primary
+	Replicas []ReplicaConfig `mapstructure:"replicas"`
+	// ReplicaHealthInterval is how often every replica is pinged. Unhealthy replicas receive no reads
+	ReplicaHealthInterval time.Duration `mapstructure:"replica_health_interval"`
+}
+
+type ReplicaConfig struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+}
+
+// HTTPClientConfig configures the client features call other services with
+type HTTPClientConfig struct {
+	// Timeout bounds every attempt of a call, including reading the response body
+	Timeout time.Duration `mapstructure:"timeout"`
+	// HostTimeouts overrides Timeout for the hosts it lists, by host name
+	HostTimeouts map[string]time.Duration `mapstructure:"host_timeouts"`
+	// Retries is how many times an idempotent call is retried after a network error or a 502, 503 or 504.
+	// A call can take up to (Retries + 1) × Timeout plus the backoffs, unless its context ends first.
+	Retries int `mapstructure:"retries"`
+	// RetryBackoff is the wait before the first retry, doubled after every attempt
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+}
+
+// LogConfig configures the logs and the access log written for every request
+type LogConfig struct {
+	// Level is the lowest level written: debug, info, warn or error. Defaults to info
+	Level string `mapstructure:"level"`
+	// Output is stdout or stderr. Defaults to stdout
+	Output    string          `mapstructure:"output"`
+	AccessLog AccessLogConfig `mapstructure:"access_log"`
+}
+
+type AccessLogConfig struct {
+	// SampleRate is the share of requests logged, from 0 to 1. Defaults to 1.
+	// Failed requests and repeated query warnings are logged whatever the rate.
+	SampleRate *float64 `mapstructure:"sample_rate"`
+	// Routes overrides SampleRate for the routes it lists
+	Routes []RouteLogConfig `mapstructure:"routes"`
+	// SkipPaths are never logged, such as health checks. They match the path or the route
+	SkipPaths []string `mapstructure:"skip_paths"`
+	// Headers are the request headers logged, by name
+	Headers []string `mapstructure:"headers"`
+	// RequestBody and ResponseBody log the bodies of sampled requests, up to MaxBodySize bytes
+	RequestBody  bool `mapstructure:"request_body"`
+	ResponseBody bool `mapstructure:"response_body"`
+	// MaxBodySize caps the bytes of each body kept in memory and logged. Defaults to 4KB
+	MaxBodySize int `mapstructure:"max_body_size"`
+	// RedactFields are logged as [REDACTED] when a header name or body field contains one of them,
+	// case insensitive. They add to the default password, token, secret, authorization and cookie
+	RedactFields []string `mapstructure:"redact_fields"`
+	// RepeatedQueryThreshold is how many times a request runs a query before it is reported as a likely N+1.
+	// Defaults to 5, a negative value disables the warning
+	RepeatedQueryThreshold int `mapstructure:"repeated_query_threshold"`
+}
+
+type RouteLogConfig struct {
+	// Route is the route pattern, such as /v1/albums/:id
+	Route      string  `mapstructure:"route"`
+	SampleRate float64 `mapstructure:"sample_rate"`
 }
 
 type UptraceConfig struct {
@@ -35,18 +134,30 @@ type UptraceConfig struct {
 	Endpoint string `mapstructure:"endpoint"`
 }
 
+type PaginationConfig struct {
+	// CursorSecret signs keyset pagination cursors. Every instance must share the same value
+	CursorSecret string `mapstructure:"cursor_secret"`
+}
+
 type ConfigFile struct {
-	AppName string            `mapstructure:"app_name"`
-	Redis   RedisClientConfig `mapstructure:"redis"`
-	DB      DatabaseConfig    `mapstructure:"database"`
-	Uptrace UptraceConfig     `mapstructure:"uptrace"`
-	Server  ServerConfig      `mapstructure:"server"`
+	AppName    string            `mapstructure:"app_name"`
+	Redis      RedisClientConfig `mapstructure:"redis"`
+	Cache      CacheConfig       `mapstructure:"cache"`
+	DB         DatabaseConfig    `mapstructure:"database"`
+	Uptrace    UptraceConfig     `mapstructure:"uptrace"`
+	Server     ServerConfig      `mapstructure:"server"`
+	Pagination PaginationConfig  `mapstructure:"pagination"`
+	HTTPClient HTTPClientConfig  `mapstructure:"http_client"`
+	Log        LogConfig         `mapstructure:"log"`
 }
 
 var configFile ConfigFile
 
+// initialized is set by Init. ConfigFile holds slices, so it can't be compared to its zero value
+var initialized bool
+
 func GetConfig() ConfigFile {
-	if configFile == (ConfigFile{}) {
+	if !initialized {
 		panic(fmt.Errorf("Config File not initialized. This indicates that the main app was not setup correctly. Make sure to call config.Init() in main.go"))
 
 	}
@@ -72,5 +183,6 @@ func Init() error {
 	if err := viper.Unmarshal(&configFile); err != nil {
 		return fmt.Errorf("fatal error config file: %w", err)
 	}
+	initialized = true
 	return nil
 }
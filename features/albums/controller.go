@@ -1,13 +1,25 @@
 package albums
 
 import (
+	"example/web-service-gin/app/apiErrors"
+	"example/web-service-gin/app/db"
+	"example/web-service-gin/app/pagination"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AlbumController interface {
 	GetAlbums(c *gin.Context)
+	GetAlbum(c *gin.Context)
+	CreateAlbum(c *gin.Context)
+	UpdateAlbum(c *gin.Context)
+	PatchAlbum(c *gin.Context)
+	DeleteAlbum(c *gin.Context)
 }
 
 type albumController struct {
@@ -19,13 +31,153 @@ func NewAlbumController(albumService AlbumService) AlbumController {
 }
 
 func (ac *albumController) GetAlbums(c *gin.Context) {
-	artist := c.Query("artist")
 	ctx := c.Request.Context()
-	params := GetAlbumsParams{Artist: artist, Limit: 10, Page: 1}
+	params, err := parseGetAlbumsParams(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	albums, err := ac.albumService.GetAlbums(ctx, params)
 	if err != nil {
 		c.Error(err)
 		return
 	}
+	pagination.SetLinkHeader(c, albums)
 	c.IndentedJSON(http.StatusOK, albums)
 }
+
+// parseGetAlbumsParams validates the list filters, sort order and pagination of GET /v1/albums
+func parseGetAlbumsParams(c *gin.Context) (GetAlbumsParams, error) {
+	pageParams, err := pagination.ParseQuery(c)
+	if err != nil {
+		return GetAlbumsParams{}, err
+	}
+	params := GetAlbumsParams{
+		Title: strings.TrimSpace(c.Query("title")),
+		Limit: pageParams.Limit,
+		Page:  pageParams.Page,
+	}
+
+	for _, artist := range c.QueryArray("artist") {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			params.Artists = append(params.Artists, artist)
+		}
+	}
+
+	if params.MinPrice, err = parsePrice(c, "minPrice"); err != nil {
+		return GetAlbumsParams{}, err
+	}
+	if params.MaxPrice, err = parsePrice(c, "maxPrice"); err != nil {
+		return GetAlbumsParams{}, err
+	}
+	if params.MinPrice != nil && params.MaxPrice != nil && *params.MinPrice > *params.MaxPrice {
+		return GetAlbumsParams{}, apiErrors.NewBadRequestError("minPrice must not be greater than maxPrice")
+	}
+
+	if params.Sort, err = parseSort(c.Query("sort")); err != nil {
+		return GetAlbumsParams{}, err
+	}
+
+	if pageParams.Cursor != "" {
+		if len(params.Sort) > 0 {
+			return GetAlbumsParams{}, apiErrors.NewBadRequestError("sort cannot be combined with cursor")
+		}
+		if params.Cursor, err = db.DecodeCursor[string](pageParams.Cursor); err != nil {
+			return GetAlbumsParams{}, err
+		}
+	}
+	return params, nil
+}
+
+func parsePrice(c *gin.Context, key string) (*float64, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(raw, 64)
+	if err != nil || price < 0 || math.IsNaN(price) || math.IsInf(price, 0) {
+		return nil, apiErrors.NewBadRequestError(fmt.Sprintf("%s must be a non negative number", key))
+	}
+	return &price, nil
+}
+
+// parseSort turns "price,-title" into sort fields, rejecting fields that are not in sortableColumns
+func parseSort(raw string) ([]SortField, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Descending: strings.HasPrefix(part, "-")}
+		if _, ok := sortableColumns[field.Field]; !ok {
+			return nil, apiErrors.NewBadRequestError(fmt.Sprintf("cannot sort by %q", field.Field))
+		}
+		if seen[field.Field] {
+			return nil, apiErrors.NewBadRequestError(fmt.Sprintf("%q is sorted more than once", field.Field))
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (ac *albumController) GetAlbum(c *gin.Context) {
+	album, err := ac.albumService.GetAlbum(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, album)
+}
+
+func (ac *albumController) CreateAlbum(c *gin.Context) {
+	var request CreateAlbumRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apiErrors.NewBadRequestError(err.Error()))
+		return
+	}
+	album, err := ac.albumService.CreateAlbum(c.Request.Context(), request.ToAlbum())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.IndentedJSON(http.StatusCreated, album)
+}
+
+func (ac *albumController) UpdateAlbum(c *gin.Context) {
+	var request UpdateAlbumRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apiErrors.NewBadRequestError(err.Error()))
+		return
+	}
+	album, err := ac.albumService.UpdateAlbum(c.Request.Context(), request.ToAlbum(c.Param("id")))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, album)
+}
+
+func (ac *albumController) PatchAlbum(c *gin.Context) {
+	var request PatchAlbumRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Error(apiErrors.NewBadRequestError(err.Error()))
+		return
+	}
+	album, err := ac.albumService.PatchAlbum(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, album)
+}
+
+func (ac *albumController) DeleteAlbum(c *gin.Context) {
+	if err := ac.albumService.DeleteAlbum(c.Request.Context(), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"example/web-service-gin/app/apiErrors"
 	"example/web-service-gin/app/db"
+	"example/web-service-gin/app/middleware"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -29,6 +32,61 @@ func (m *MockAlbumService) GetAlbums(ctx context.Context, params GetAlbumsParams
 	return paginated, args.Error(1)
 }
 
+func (m *MockAlbumService) GetAlbum(ctx context.Context, id string) (*Album, error) {
+	args := m.Called(ctx, id)
+	return albumOrNil(args.Get(0)), args.Error(1)
+}
+
+func (m *MockAlbumService) CreateAlbum(ctx context.Context, album Album) (*Album, error) {
+	args := m.Called(ctx, album)
+	return albumOrNil(args.Get(0)), args.Error(1)
+}
+
+func (m *MockAlbumService) UpdateAlbum(ctx context.Context, album Album) (*Album, error) {
+	args := m.Called(ctx, album)
+	return albumOrNil(args.Get(0)), args.Error(1)
+}
+
+func (m *MockAlbumService) PatchAlbum(ctx context.Context, id string, patch PatchAlbumRequest) (*Album, error) {
+	args := m.Called(ctx, id, patch)
+	return albumOrNil(args.Get(0)), args.Error(1)
+}
+
+func (m *MockAlbumService) DeleteAlbum(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockAlbumService) ImportAlbums(ctx context.Context, albums []Album) (*ImportResult, error) {
+	args := m.Called(ctx, albums)
+	result, _ := args.Get(0).(*ImportResult)
+	return result, args.Error(1)
+}
+
+func albumOrNil(result interface{}) *Album {
+	if result == nil {
+		return nil
+	}
+	return result.(*Album)
+}
+
+// serveAlbums runs the request through a router so path params and the ErrorHandler are applied
+func serveAlbums(controller AlbumController, method string, path string, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(middleware.ErrorHandler)
+	router.GET("/v1/albums/:id", controller.GetAlbum)
+	router.POST("/v1/albums", controller.CreateAlbum)
+	router.PUT("/v1/albums/:id", controller.UpdateAlbum)
+	router.PATCH("/v1/albums/:id", controller.PatchAlbum)
+	router.DELETE("/v1/albums/:id", controller.DeleteAlbum)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func TestGetAlbums(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -43,9 +101,9 @@ func TestGetAlbums(t *testing.T) {
 		}
 
 		mockService.On("GetAlbums", mock.Anything, GetAlbumsParams{
-			Artist: "John Coltrane",
-			Limit:  10,
-			Page:   1,
+			Artists: []string{"John Coltrane"},
+			Limit:   10,
+			Page:    1,
 		}).Return(&expectedAlbums, nil)
 
 		w := httptest.NewRecorder()
@@ -64,14 +122,89 @@ func TestGetAlbums(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 
-	t.Run("Error from service", func(t *testing.T) {
+	t.Run("Page and limit from the query", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		expectedAlbums := db.NewPaginated([]Album{
+			{ID: "3", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99},
+		}, 5, 2, 2)
+
+		mockService.On("GetAlbums", mock.Anything, GetAlbumsParams{
+			Limit: 2,
+			Page:  2,
+		}).Return(expectedAlbums, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/v1/albums?page=2&limit=2", nil)
+
+		controller.GetAlbums(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t,
+			`</v1/albums?limit=2&page=1>; rel="first", </v1/albums?limit=2&page=1>; rel="prev", </v1/albums?limit=2&page=3>; rel="next", </v1/albums?limit=2&page=3>; rel="last"`,
+			w.Header().Get("Link"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid page", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/v1/albums?page=0", nil)
+
+		controller.GetAlbums(c)
+
+		assert.Equal(t, 1, len(c.Errors))
+		mockService.AssertNotCalled(t, "GetAlbums", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Cursor from the query", func(t *testing.T) {
 		mockService := new(MockAlbumService)
 		controller := NewAlbumController(mockService)
 
+		token, _ := db.EncodeCursor(db.Cursor[string]{Key: "10", Direction: db.CursorNext})
 		mockService.On("GetAlbums", mock.Anything, GetAlbumsParams{
-			Artist: "Unknown",
 			Limit:  10,
-			Page:   1,
+			Cursor: &db.Cursor[string]{Key: "10", Direction: db.CursorNext},
+		}).Return(&db.Paginated[Album]{Items: []Album{{ID: "11"}}, Limit: 10}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/v1/albums?cursor="+token, nil)
+
+		controller.GetAlbums(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/v1/albums?cursor=forged", nil)
+
+		controller.GetAlbums(c)
+
+		assert.Equal(t, 1, len(c.Errors))
+		assert.Equal(t, db.InvalidCursorError, c.Errors[0].Err)
+		mockService.AssertNotCalled(t, "GetAlbums", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Error from service", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		mockService.On("GetAlbums", mock.Anything, GetAlbumsParams{
+			Artists: []string{"Unknown"},
+			Limit:   10,
+			Page:    1,
 		}).Return(nil, errors.New("service error"))
 
 		w := httptest.NewRecorder()
@@ -87,3 +220,209 @@ func TestGetAlbums(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 }
+
+func TestGetAlbum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Successful retrieval", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		expectedAlbum := Album{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99}
+		mockService.On("GetAlbum", mock.Anything, "1").Return(&expectedAlbum, nil)
+
+		w := serveAlbums(controller, http.MethodGet, "/v1/albums/1", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response Album
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, expectedAlbum, response)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		mockService.On("GetAlbum", mock.Anything, "404").Return(nil, db.NotFoundError)
+
+		w := serveAlbums(controller, http.MethodGet, "/v1/albums/404", "")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"error":{"code":"not_found","message":"resource not found"}}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestCreateAlbum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Successful creation", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		album := Album{ID: "21", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99}
+		mockService.On("CreateAlbum", mock.Anything, album).Return(&album, nil)
+
+		w := serveAlbums(controller, http.MethodPost, "/v1/albums", `{"id":"21","title":"Blue Train","artist":"John Coltrane","price":56.99}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response Album
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, album, response)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Missing required fields", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		w := serveAlbums(controller, http.MethodPost, "/v1/albums", `{"id":"21"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "CreateAlbum", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Duplicate album", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		album := Album{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99}
+		mockService.On("CreateAlbum", mock.Anything, album).Return(nil, db.UniqueViolationError)
+
+		w := serveAlbums(controller, http.MethodPost, "/v1/albums", `{"id":"1","title":"Blue Train","artist":"John Coltrane","price":56.99}`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestUpdateAlbum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Successful update", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		album := Album{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 10}
+		mockService.On("UpdateAlbum", mock.Anything, album).Return(&album, nil)
+
+		w := serveAlbums(controller, http.MethodPut, "/v1/albums/1", `{"title":"Blue Train","artist":"John Coltrane","price":10}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid body", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		w := serveAlbums(controller, http.MethodPut, "/v1/albums/1", `{"title":"Blue Train","artist":"John Coltrane","price":-1}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "UpdateAlbum", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		album := Album{ID: "404", Title: "Blue Train", Artist: "John Coltrane", Price: 10}
+		mockService.On("UpdateAlbum", mock.Anything, album).Return(nil, db.NotFoundError)
+
+		w := serveAlbums(controller, http.MethodPut, "/v1/albums/404", `{"title":"Blue Train","artist":"John Coltrane","price":10}`)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestPatchAlbum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockAlbumService)
+	controller := NewAlbumController(mockService)
+
+	price := 12.5
+	patched := Album{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: price}
+	mockService.On("PatchAlbum", mock.Anything, "1", PatchAlbumRequest{Price: &price}).Return(&patched, nil)
+
+	w := serveAlbums(controller, http.MethodPatch, "/v1/albums/1", `{"price":12.5}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response Album
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, patched, response)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteAlbum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Successful delete", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		mockService.On("DeleteAlbum", mock.Anything, "1").Return(nil)
+
+		w := serveAlbums(controller, http.MethodDelete, "/v1/albums/1", "")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockService := new(MockAlbumService)
+		controller := NewAlbumController(mockService)
+
+		mockService.On("DeleteAlbum", mock.Anything, "404").Return(db.NotFoundError)
+
+		w := serveAlbums(controller, http.MethodDelete, "/v1/albums/404", "")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestParseGetAlbumsParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	parse := func(target string) (GetAlbumsParams, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(http.MethodGet, target, nil)
+		return parseGetAlbumsParams(c)
+	}
+
+	t.Run("Filters and sort", func(t *testing.T) {
+		params, err := parse("/v1/albums?artist=Miles+Davis&artist=John+Coltrane&title=blue&minPrice=10&maxPrice=40.5&sort=price,-title")
+		assert.NoError(t, err)
+
+		minPrice, maxPrice := 10.0, 40.5
+		assert.Equal(t, GetAlbumsParams{
+			Artists:  []string{"Miles Davis", "John Coltrane"},
+			Title:    "blue",
+			MinPrice: &minPrice,
+			MaxPrice: &maxPrice,
+			Sort:     []SortField{{Field: "price"}, {Field: "title", Descending: true}},
+			Limit:    10,
+			Page:     1,
+		}, params)
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		token, _ := db.EncodeCursor(db.Cursor[string]{Key: "10", Direction: db.CursorNext})
+		for _, target := range []string{
+			"/v1/albums?sort=password",
+			"/v1/albums?sort=price,-price",
+			"/v1/albums?minPrice=-1",
+			"/v1/albums?maxPrice=abc",
+			"/v1/albums?minPrice=20&maxPrice=10",
+			"/v1/albums?sort=price&cursor=" + token,
+		} {
+			_, err := parse(target)
+			var apiErr *apiErrors.APIError
+			assert.ErrorAs(t, err, &apiErr, target)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Status, target)
+		}
+	})
+}
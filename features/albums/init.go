@@ -9,5 +9,9 @@ func Init(deps *dependencies.Dependencies) {
 
 	v1 := deps.Router.Group("/v1")
 	v1.GET("/albums", albumController.GetAlbums)
-	// v1.GET("/albums/:id", getAlbum)
+	v1.GET("/albums/:id", albumController.GetAlbum)
+	v1.POST("/albums", albumController.CreateAlbum)
+	v1.PUT("/albums/:id", albumController.UpdateAlbum)
+	v1.PATCH("/albums/:id", albumController.PatchAlbum)
+	v1.DELETE("/albums/:id", albumController.DeleteAlbum)
 }
@@ -2,8 +2,8 @@ package albums
 
 import (
 	"context"
-	"example/web-service-gin/app/db"
 	"example/web-service-gin/app/dependencies"
+	"example/web-service-gin/testUtils"
 	"testing"
 	"time"
 
@@ -25,6 +25,54 @@ func (rc *MockCache) Set(serviceName string, ctx context.Context, key string, va
 	return nil
 }
 
+func (rc *MockCache) SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (rc *MockCache) Delete(serviceName string, ctx context.Context, keys ...string) error {
+	return nil
+}
+
+func (rc *MockCache) CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error) {
+	return true, nil
+}
+
+func (rc *MockCache) SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error {
+	return nil
+}
+
+func (rc *MockCache) InvalidateTags(serviceName string, ctx context.Context, tags ...string) error {
+	return nil
+}
+
+func (rc *MockCache) MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (rc *MockCache) MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error {
+	return nil
+}
+
+func (rc *MockCache) TTL(serviceName string, ctx context.Context, key string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (rc *MockCache) Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error {
+	return nil
+}
+
+func (rc *MockCache) Exists(serviceName string, ctx context.Context, key string) (bool, error) {
+	return false, nil
+}
+
+func (rc *MockCache) Incr(serviceName string, ctx context.Context, key string) (int64, error) {
+	return 0, nil
+}
+
+func (rc *MockCache) Decr(serviceName string, ctx context.Context, key string) (int64, error) {
+	return 0, nil
+}
+
 func TestInit(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -36,14 +84,14 @@ func TestInit(t *testing.T) {
 		}
 		defer client.Close()
 
-		database := db.Database{Client: client}
+		database := testUtils.NewDatabase(client)
 
 		mockCache := new(MockCache)
 
 		router := gin.Default()
 
 		deps := &dependencies.Dependencies{
-			DB:     &database,
+			DB:     database,
 			Cache:  mockCache,
 			Router: router,
 		}
@@ -53,11 +101,18 @@ func TestInit(t *testing.T) {
 
 		// Assert
 		routes := router.Routes()
-		assert.Len(t, routes, 1, "Should have 1 route")
+		assert.Len(t, routes, 6, "Should have 6 routes")
 
-		route := routes[0]
-		assert.Equal(t, "GET", route.Method, "Route method should be GET")
-		assert.Equal(t, "/v1/albums", route.Path, "Route path should be /v1/albums")
+		registered := make(map[string]bool)
+		for _, route := range routes {
+			registered[route.Method+" "+route.Path] = true
+		}
+		assert.True(t, registered["GET /v1/albums"], "Route GET /v1/albums should be registered")
+		assert.True(t, registered["GET /v1/albums/:id"], "Route GET /v1/albums/:id should be registered")
+		assert.True(t, registered["POST /v1/albums"], "Route POST /v1/albums should be registered")
+		assert.True(t, registered["PUT /v1/albums/:id"], "Route PUT /v1/albums/:id should be registered")
+		assert.True(t, registered["PATCH /v1/albums/:id"], "Route PATCH /v1/albums/:id should be registered")
+		assert.True(t, registered["DELETE /v1/albums/:id"], "Route DELETE /v1/albums/:id should be registered")
 	})
 
 }
@@ -1,14 +1,111 @@
 package albums
 
+import (
+	"example/web-service-gin/app/db"
+	"sort"
+)
+
 type Album struct {
-	ID     string  `json:"id"`
-	Title  string  `json:"title"`
-	Artist string  `json:"artist"`
-	Price  float64 `json:"price"`
+	ID     string  `json:"id" db:"id"`
+	Title  string  `json:"title" db:"title"`
+	Artist string  `json:"artist" db:"artist"`
+	Price  float64 `json:"price" db:"price"`
+}
+
+// sortableColumns is the whitelist of ?sort= fields mapped to their albums table column
+var sortableColumns = map[string]string{
+	"id":     "id",
+	"title":  "title",
+	"artist": "artist",
+	"price":  "price",
+}
+
+// SortField is one entry of ?sort=price,-title. A leading "-" sorts descending
+type SortField struct {
+	Field      string
+	Descending bool
 }
 
 type GetAlbumsParams struct {
-	Artist string
-	Limit  int
-	Page   int
+	// Artists matches any of the artists case insensitively
+	Artists []string
+	// Title matches albums whose title contains the text case insensitively
+	Title    string
+	MinPrice *float64
+	MaxPrice *float64
+	Sort     []SortField
+	Limit    int
+	Page     int
+	// Cursor switches the listing to keyset pagination on the album id. Page is ignored when it is set.
+	Cursor *db.Cursor[string]
+}
+
+// normalized returns a copy where filters that don't depend on order are sorted,
+// so equivalent requests build the same cache key
+func (p GetAlbumsParams) normalized() GetAlbumsParams {
+	p.Artists = append([]string(nil), p.Artists...)
+	sort.Strings(p.Artists)
+	return p
+}
+
+// ImportResult counts the albums an import inserted and the existing ones it updated
+type ImportResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+}
+
+// CreateAlbumRequest is the body accepted by POST /v1/albums
+type CreateAlbumRequest struct {
+	ID     string  `json:"id" binding:"required"`
+	Title  string  `json:"title" binding:"required"`
+	Artist string  `json:"artist" binding:"required"`
+	Price  float64 `json:"price" binding:"gte=0"`
+}
+
+// UpdateAlbumRequest is the body accepted by PUT /v1/albums/:id
+// Every field is replaced, the id is taken from the path
+type UpdateAlbumRequest struct {
+	Title  string  `json:"title" binding:"required"`
+	Artist string  `json:"artist" binding:"required"`
+	Price  float64 `json:"price" binding:"gte=0"`
+}
+
+// PatchAlbumRequest is the body accepted by PATCH /v1/albums/:id
+// Only the fields that are present are changed
+type PatchAlbumRequest struct {
+	Title  *string  `json:"title" binding:"omitempty,min=1"`
+	Artist *string  `json:"artist" binding:"omitempty,min=1"`
+	Price  *float64 `json:"price" binding:"omitempty,gte=0"`
+}
+
+func (r CreateAlbumRequest) ToAlbum() Album {
+	return Album{
+		ID:     r.ID,
+		Title:  r.Title,
+		Artist: r.Artist,
+		Price:  r.Price,
+	}
+}
+
+func (r UpdateAlbumRequest) ToAlbum(id string) Album {
+	return Album{
+		ID:     id,
+		Title:  r.Title,
+		Artist: r.Artist,
+		Price:  r.Price,
+	}
+}
+
+// Apply returns a copy of album with the fields present in the patch applied
+func (r PatchAlbumRequest) Apply(album Album) Album {
+	if r.Title != nil {
+		album.Title = *r.Title
+	}
+	if r.Artist != nil {
+		album.Artist = *r.Artist
+	}
+	if r.Price != nil {
+		album.Price = *r.Price
+	}
+	return album
 }
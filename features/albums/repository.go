@@ -14,85 +14,371 @@ const (
 
 type AlbumRepository interface {
 	GetAlbums(ctx context.Context, params GetAlbumsParams) (*db.Paginated[Album], error)
+	GetAlbum(ctx context.Context, id string) (*Album, error)
 	Insert(ctx context.Context, album Album) error
-	InsertBatch(ctx context.Context, album []Album) error
+	// InsertBatch inserts the albums, updating the ones that already exist, in a single transaction
+	InsertBatch(ctx context.Context, albums []Album) (*ImportResult, error)
+	Update(ctx context.Context, album Album) error
+	// Patch applies the patch to the album in a transaction holding its row lock and returns the result
+	Patch(ctx context.Context, id string, patch PatchAlbumRequest) (*Album, error)
+	Delete(ctx context.Context, id string) error
 }
 
+const (
+	// defaultBatchSize keeps every INSERT of InsertBatch small, far below db.MaxParameters
+	defaultBatchSize = 1000
+	// defaultCopyThreshold is the batch size from which COPY beats multi row INSERTs
+	defaultCopyThreshold = 10_000
+)
+
 type albumRepository struct {
 	dbConn db.Database
+	// batchSize is the number of albums written by each INSERT of InsertBatch
+	batchSize int
+	// copyThreshold is the number of albums from which InsertBatch loads them with COPY
+	copyThreshold int
 }
 
 func NewAlbumRepository(dbConn db.Database) AlbumRepository {
 	return &albumRepository{
-		dbConn: dbConn,
+		dbConn:        dbConn,
+		batchSize:     defaultBatchSize,
+		copyThreshold: defaultCopyThreshold,
 	}
 }
 
+const albumsTable = "albums"
+
 func (ar *albumRepository) GetAlbums(ctx context.Context, params GetAlbumsParams) (*db.Paginated[Album], error) {
+	query := db.Select(albumsTable, db.Columns[Album]()...)
+	filterAlbums(query, params)
 
-	var artist = params.Artist
-	var query string
-	var args []interface{}
-	offset := (params.Page * -1) - params.Limit
-	if offset < 0 {
-		offset = 0
+	if params.Cursor != nil {
+		return ar.getAlbumsAfterCursor(ctx, params, query)
 	}
 
-	if artist != "" {
-		query = "SELECT * FROM albums WHERE artist ILIKE $1 LIMIT $2 OFFSET $3"
-		args = []interface{}{artist, params.Limit, offset}
-	} else {
-		query = "SELECT * FROM albums LIMIT $1 OFFSET $2"
-		args = []interface{}{params.Limit, offset}
+	countQuery := query.Count()
+	query.OrderBy(sortOrder(params.Sort)...).Limit(params.Limit).Offset(db.Offset(params.Page, params.Limit))
+
+	albums, err := ar.queryAlbums(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(albums) == 0 {
+		return nil, db.MapDBError(&sql.ErrNoRows)
 	}
 
-	rows, err := ar.dbConn.QueryContext(serviceName, ctx, query, args...)
+	total, err := ar.count(ctx, countQuery)
 	if err != nil {
-		return nil, db.MapDBError(&err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	var albums []Album
-	for rows.Next() {
-		var album Album
-		if err := rows.Scan(&album.ID, &album.Title, &album.Artist, &album.Price); err != nil {
+	page := db.NewPaginated(albums, total, max(params.Page, 1), params.Limit)
+	if page.HasNext && len(params.Sort) == 0 {
+		// Offset pages are ordered by id as well, so clients can switch to keyset mode from any page
+		nextCursor, err := db.EncodeCursor(db.Cursor[string]{Key: albums[len(albums)-1].ID, Direction: db.CursorNext})
+		if err != nil {
 			return nil, db.MapDBError(&err)
 		}
-		albums = append(albums, album)
+		page.NextCursor = nextCursor
+	}
+	return page, nil
+}
 
+// getAlbumsAfterCursor seeks on the album id instead of using OFFSET so each page costs the same
+// and rows inserted while a client is walking the list don't shift it into duplicates.
+func (ar *albumRepository) getAlbumsAfterCursor(ctx context.Context, params GetAlbumsParams, query *db.SelectQuery) (*db.Paginated[Album], error) {
+	if params.Cursor.Direction == db.CursorPrev {
+		query.Where("id < ?", params.Cursor.Key).OrderBy(db.Order{Column: "id", Descending: true})
+	} else {
+		query.Where("id > ?", params.Cursor.Key).OrderBy(db.Order{Column: "id"})
+	}
+	query.Limit(params.Limit + 1)
+
+	albums, err := ar.queryAlbums(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(albums) == 0 {
 		return nil, db.MapDBError(&sql.ErrNoRows)
 	}
 
-	return &db.Paginated[Album]{
-		Items: albums,
-	}, nil
+	page, err := db.NewKeysetPaginated(albums, params.Limit, params.Cursor, func(album Album) string { return album.ID })
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	return page, nil
+}
+
+func (ar *albumRepository) queryAlbums(ctx context.Context, query *db.SelectQuery) ([]Album, error) {
+	text, args, err := query.Build()
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	rows, err := ar.dbConn.QueryContext(serviceName, ctx, text, args...)
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	albums, err := db.ScanAll[Album](rows)
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	return albums, nil
+}
+
+// likeEscaper escapes the ILIKE wildcards so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterAlbums adds the conditions of the list filters to the query
+func filterAlbums(query *db.SelectQuery, params GetAlbumsParams) {
+	if len(params.Artists) > 0 {
+		artistConditions := make([]string, 0, len(params.Artists))
+		artists := make([]any, 0, len(params.Artists))
+		for _, artist := range params.Artists {
+			artistConditions = append(artistConditions, "artist ILIKE ?")
+			artists = append(artists, artist)
+		}
+		if len(artistConditions) == 1 {
+			query.Where(artistConditions[0], artists...)
+		} else {
+			query.Where("("+strings.Join(artistConditions, " OR ")+")", artists...)
+		}
+	}
+	if params.Title != "" {
+		query.Where("title ILIKE ?", "%"+likeEscaper.Replace(params.Title)+"%")
+	}
+	if params.MinPrice != nil {
+		query.Where("price >= ?", *params.MinPrice)
+	}
+	if params.MaxPrice != nil {
+		query.Where("price <= ?", *params.MaxPrice)
+	}
+}
+
+// sortOrder builds the ORDER BY from whitelisted columns only.
+// id is always the last key so rows with equal values keep a stable order between pages.
+func sortOrder(sort []SortField) []db.Order {
+	orders := make([]db.Order, 0, len(sort)+1)
+	sortedById := false
+	for _, field := range sort {
+		column, ok := sortableColumns[field.Field]
+		if !ok {
+			continue
+		}
+		sortedById = sortedById || column == "id"
+		orders = append(orders, db.Order{Column: column, Descending: field.Descending})
+	}
+	if !sortedById {
+		orders = append(orders, db.Order{Column: "id"})
+	}
+	return orders
+}
+
+func (ar *albumRepository) count(ctx context.Context, query *db.SelectQuery) (int, error) {
+	text, args, err := query.Build()
+	if err != nil {
+		return 0, db.MapDBError(&err)
+	}
+	var total int
+	if err := ar.dbConn.QueryRowContext(serviceName, ctx, text, args...).Scan(&total); err != nil {
+		return 0, db.MapDBError(&err)
+	}
+	return total, nil
+}
+
+// GetAlbum runs on every album page view, so its query is prepared once and reused. The text doesn't
+// depend on the id, so every call shares the statement. It selects the columns of Album in field order,
+// as ScanOne expects.
+func (ar *albumRepository) GetAlbum(ctx context.Context, id string) (*Album, error) {
+	query, args, err := db.Select(albumsTable, db.Columns[Album]()...).Where("id = ?", id).Build()
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	stmt, err := ar.dbConn.Prepare(serviceName, ctx, query)
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+
+	album, err := db.ScanOne[Album](stmt.QueryRowContext(serviceName, ctx, args...))
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	return album, nil
 }
 
 func (ar *albumRepository) Insert(ctx context.Context, album Album) error {
-	_, err := ar.dbConn.ExecContext(serviceName, ctx, "INSERT INTO albums (id, title, artist, price) VALUES ($1, $2, $3, $4)", album.ID, album.Title, album.Artist, album.Price)
+	query, args, err := db.InsertInto(albumsTable, db.Columns[Album]()...).Values(db.FieldValues(album)...).Build()
+	if err != nil {
+		return db.MapDBError(&err)
+	}
+	_, err = ar.dbConn.ExecContext(serviceName, ctx, query, args...)
+	if err != nil {
+		return db.MapDBError(&err)
+	}
+	return nil
+}
+
+// Update overwrites every column of the album but its id
+func (ar *albumRepository) Update(ctx context.Context, album Album) error {
+	query, args, err := updateAlbumQuery(album)
+	if err != nil {
+		return db.MapDBError(&err)
+	}
+	return ar.exec(ctx, query, args)
+}
+
+// updateAlbumQuery sets every column of the album but its id
+func updateAlbumQuery(album Album) (string, []any, error) {
+	update := db.Update(albumsTable).Where("id = ?", album.ID)
+	values := db.FieldValues(album)
+	for i, column := range db.Columns[Album]() {
+		if column != "id" {
+			update.Set(column, values[i])
+		}
+	}
+	return update.Build()
+}
+
+// Patch locks the row of the album while the patch is applied to it, so concurrent patches of the
+// album run one after the other and each one sees the fields the previous ones changed. The read
+// joins the transaction, which runs on the primary, so it can't go through the prepared GetAlbum.
+func (ar *albumRepository) Patch(ctx context.Context, id string, patch PatchAlbumRequest) (*Album, error) {
+	query, args, err := db.Select(albumsTable, db.Columns[Album]()...).Where("id = ?", id).ForUpdate().Build()
 	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+
+	var patched Album
+	err = ar.dbConn.WithTx(ctx, nil, func(ctx context.Context) error {
+		existing, err := db.ScanOne[Album](ar.dbConn.QueryRowContext(serviceName, ctx, query, args...))
+		if err != nil {
+			return err
+		}
+		patched = patch.Apply(*existing)
+		update, updateArgs, err := updateAlbumQuery(patched)
+		if err != nil {
+			return err
+		}
+		// The locked row can't be deleted before the commit, so the update always finds it
+		_, err = ar.dbConn.ExecContext(serviceName, ctx, update, updateArgs...)
 		return err
+	})
+	if err != nil {
+		return nil, db.MapDBError(&err)
+	}
+	return &patched, nil
+}
+
+func (ar *albumRepository) Delete(ctx context.Context, id string) error {
+	query, args, err := db.DeleteFrom(albumsTable).Where("id = ?", id).Build()
+	if err != nil {
+		return db.MapDBError(&err)
+	}
+	return ar.exec(ctx, query, args)
+}
+
+// exec runs a statement expected to change a row, see expectAffectedRows
+func (ar *albumRepository) exec(ctx context.Context, query string, args []any) error {
+	result, err := ar.dbConn.ExecContext(serviceName, ctx, query, args...)
+	if err != nil {
+		return db.MapDBError(&err)
+	}
+	return expectAffectedRows(result)
+}
+
+// expectAffectedRows turns a statement that touched no rows into a not found error
+func expectAffectedRows(result *sql.Result) error {
+	affected, err := (*result).RowsAffected()
+	if err != nil {
+		return db.MapDBError(&err)
+	}
+	if affected == 0 {
+		return db.MapDBError(&sql.ErrNoRows)
 	}
 	return nil
 }
 
-func (ar *albumRepository) InsertBatch(ctx context.Context, albums []Album) error {
+const (
+	albumsImportTable  = "albums_import"
+	upsertAlbumsClause = "ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, artist = EXCLUDED.artist, price = EXCLUDED.price"
+)
+
+// InsertBatch writes the albums in chunks of batchSize, or with COPY from copyThreshold albums on,
+// and reports how many were inserted and how many updated. Every chunk is committed together,
+// so a failed import leaves the albums as they were.
+func (ar *albumRepository) InsertBatch(ctx context.Context, albums []Album) (*ImportResult, error) {
+	result := &ImportResult{}
 	if len(albums) == 0 {
+		return result, nil
+	}
+
+	err := ar.dbConn.WithTx(ctx, nil, func(ctx context.Context) error {
+		// The transaction may run again after a serialization failure
+		*result = ImportResult{}
+		if len(albums) >= ar.copyThreshold {
+			return ar.copyAlbums(ctx, albums, result)
+		}
+		for _, chunk := range db.Chunk(albums, ar.batchSize) {
+			if err := ar.upsertAlbums(ctx, chunk, result); err != nil {
+				return err
+			}
+		}
 		return nil
+	})
+	if err != nil {
+		return nil, db.MapDBError(&err)
 	}
+	return result, nil
+}
 
-	values := make([]string, 0, len(albums))
-	args := make([]interface{}, 0, len(albums)*4)
-	for i, album := range albums {
-		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
-		args = append(args, album.ID, album.Title, album.Artist, album.Price)
+// upsertAlbums writes the albums with a single INSERT and adds the counts to result
+func (ar *albumRepository) upsertAlbums(ctx context.Context, albums []Album, result *ImportResult) error {
+	insert := db.InsertInto(albumsTable, db.Columns[Album]()...)
+	for _, album := range albums {
+		insert.Values(db.FieldValues(album)...)
 	}
+	query, args, err := insert.Suffix(upsertAlbumsClause).Build()
+	if err != nil {
+		return err
+	}
+	return ar.countUpserted(ctx, query, args, result)
+}
 
-	query := fmt.Sprintf("INSERT INTO albums (id, title, artist, price) VALUES %s ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, artist = EXCLUDED.artist, price = EXCLUDED.price", strings.Join(values, ","))
+// copyAlbums loads the albums into a temporary table with COPY, which can't resolve conflicts itself,
+// and upserts them from there into albums
+func (ar *albumRepository) copyAlbums(ctx context.Context, albums []Album, result *ImportResult) error {
+	if _, err := ar.dbConn.ExecContext(serviceName, ctx, "CREATE TEMP TABLE "+albumsImportTable+" (LIKE albums INCLUDING DEFAULTS) ON COMMIT DROP"); err != nil {
+		return err
+	}
+	columns := db.Columns[Album]()
+	if _, err := ar.dbConn.CopyIn(serviceName, ctx, albumsImportTable, columns, len(albums), func(i int) []any {
+		return db.FieldValues(albums[i])
+	}); err != nil {
+		return err
+	}
 
-	_, err := ar.dbConn.ExecContext(serviceName, ctx, query, args...)
+	query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s %s", albumsTable, strings.Join(columns, ", "), strings.Join(columns, ", "), albumsImportTable, upsertAlbumsClause)
+	if err := ar.countUpserted(ctx, query, nil, result); err != nil {
+		return err
+	}
+	// Dropped now rather than at commit, in case the transaction imports again
+	_, err := ar.dbConn.ExecContext(serviceName, ctx, "DROP TABLE "+albumsImportTable)
 	return err
 }
+
+// countUpserted runs an INSERT ... ON CONFLICT DO UPDATE and adds the rows it inserted and updated to result.
+// xmax is only set on the rows an update replaced, so it tells the two apart.
+func (ar *albumRepository) countUpserted(ctx context.Context, insert string, args []any, result *ImportResult) error {
+	query := "WITH upserted AS (" + insert + " RETURNING (xmax = 0) AS inserted) " +
+		"SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted) FROM upserted"
+
+	var inserted, updated int
+	if err := ar.dbConn.QueryRowContext(serviceName, ctx, query, args...).Scan(&inserted, &updated); err != nil {
+		return err
+	}
+	result.Inserted += inserted
+	result.Updated += updated
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,13 +32,12 @@ func TestGetAlbumsRepository(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
 			AddRow("1", "Album 1", "Artist 1", 9.99).
 			AddRow("2", "Album 2", "Artist 2", 14.99)
-		mock.ExpectQuery("SELECT \\* FROM albums").WillReturnRows(rows)
+		mock.ExpectQuery("SELECT id, title, artist, price FROM albums").WillReturnRows(rows)
 		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM albums").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
 
 		result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
-			Artist: "",
-			Limit:  10,
-			Page:   0,
+			Limit: 10,
+			Page:  0,
 		})
 
 		expected := &db.Paginated[Album]{
@@ -50,6 +50,10 @@ func TestGetAlbumsRepository(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 2, len(result.Items))
 		assert.Equal(t, reflect.DeepEqual(expected.Items, result.Items), true)
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, 1, result.Page)
+		assert.Equal(t, 1, result.TotalPages)
+		assert.False(t, result.HasNext)
 
 	})
 
@@ -62,11 +66,12 @@ func TestGetAlbumsRepository(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
 			AddRow("1", "Album 1", "Artist 1", 9.99)
 
-		mock.ExpectQuery("SELECT \\* FROM albums WHERE artist ILIKE \\$1 LIMIT \\$2 OFFSET \\$3").WithArgs("Artist 1", 10, 0).WillReturnRows(rows)
+		mock.ExpectQuery("SELECT id, title, artist, price FROM albums WHERE artist ILIKE \\$1 ORDER BY id LIMIT \\$2 OFFSET \\$3").WithArgs("Artist 1", 10, 0).WillReturnRows(rows)
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM albums WHERE artist ILIKE \\$1").WithArgs("Artist 1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 		result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
-			Artist: "Artist 1",
-			Limit:  10,
-			Page:   0,
+			Artists: []string{"Artist 1"},
+			Limit:   10,
+			Page:    0,
 		})
 		expected := &db.Paginated[Album]{
 			Items: []Album{
@@ -80,6 +85,135 @@ func TestGetAlbumsRepository(t *testing.T) {
 
 }
 
+func TestGetAlbumsRepositoryPagination(t *testing.T) {
+	config.Init()
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+
+	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+	rows := sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
+		AddRow("3", "Album 3", "Artist 3", 9.99).
+		AddRow("4", "Album 4", "Artist 4", 14.99)
+	mock.ExpectQuery("SELECT id, title, artist, price FROM albums ORDER BY id LIMIT \\$1 OFFSET \\$2").WithArgs(2, 2).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM albums").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+	result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
+		Limit: 2,
+		Page:  2,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Total)
+	assert.Equal(t, 2, result.Page)
+	assert.Equal(t, 2, result.Limit)
+	assert.Equal(t, 3, result.TotalPages)
+	assert.True(t, result.HasNext)
+	next, err := db.DecodeCursor[string](result.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, "4", next.Key)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAlbumsRepositoryFiltersAndSort(t *testing.T) {
+	config.Init()
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+
+	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+	minPrice, maxPrice := 10.0, 50.0
+	rows := sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
+		AddRow("9", "A Love Supreme", "John Coltrane", 49.99)
+	mock.ExpectQuery("SELECT id, title, artist, price FROM albums WHERE \\(artist ILIKE \\$1 OR artist ILIKE \\$2\\) AND title ILIKE \\$3 AND price >= \\$4 AND price <= \\$5 ORDER BY price DESC, title, id LIMIT \\$6 OFFSET \\$7").
+		WithArgs("John Coltrane", "Miles Davis", "%100\\%%", minPrice, maxPrice, 10, 0).
+		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM albums WHERE \\(artist ILIKE \\$1 OR artist ILIKE \\$2\\) AND title ILIKE \\$3 AND price >= \\$4 AND price <= \\$5").
+		WithArgs("John Coltrane", "Miles Davis", "%100\\%%", minPrice, maxPrice).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
+		Artists:  []string{"John Coltrane", "Miles Davis"},
+		Title:    "100%",
+		MinPrice: &minPrice,
+		MaxPrice: &maxPrice,
+		Sort:     []SortField{{Field: "price", Descending: true}, {Field: "title"}},
+		Limit:    10,
+		Page:     1,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Items))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSortOrder(t *testing.T) {
+	assert.Equal(t, []db.Order{{Column: "id"}}, sortOrder(nil))
+	assert.Equal(t, []db.Order{{Column: "price", Descending: true}, {Column: "id"}}, sortOrder([]SortField{{Field: "price", Descending: true}}))
+	assert.Equal(t, []db.Order{{Column: "id", Descending: true}}, sortOrder([]SortField{{Field: "id", Descending: true}}))
+	assert.Equal(t, []db.Order{{Column: "id"}}, sortOrder([]SortField{{Field: "price; DROP TABLE albums"}}), "Unknown fields should never reach the query")
+}
+
+func TestGetAlbumsRepositoryCursor(t *testing.T) {
+	config.Init()
+
+	t.Run("Next page seeks after the cursor key", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		rows := sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
+			AddRow("3", "Album 3", "Artist 1", 9.99).
+			AddRow("4", "Album 4", "Artist 1", 14.99).
+			AddRow("5", "Album 5", "Artist 1", 14.99)
+		mock.ExpectQuery("SELECT id, title, artist, price FROM albums WHERE artist ILIKE \\$1 AND id > \\$2 ORDER BY id LIMIT \\$3").
+			WithArgs("Artist 1", "2", 3).
+			WillReturnRows(rows)
+
+		result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
+			Artists: []string{"Artist 1"},
+			Limit:   2,
+			Cursor:  &db.Cursor[string]{Key: "2", Direction: db.CursorNext},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Album{
+			{ID: "3", Title: "Album 3", Artist: "Artist 1", Price: 9.99},
+			{ID: "4", Title: "Album 4", Artist: "Artist 1", Price: 14.99},
+		}, result.Items)
+		assert.True(t, result.HasNext)
+		assert.Equal(t, 0, result.Total)
+
+		next, err := db.DecodeCursor[string](result.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, "4", next.Key)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Previous page seeks before the cursor key", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		rows := sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
+			AddRow("2", "Album 2", "Artist 2", 9.99).
+			AddRow("1", "Album 1", "Artist 1", 14.99)
+		mock.ExpectQuery("SELECT id, title, artist, price FROM albums WHERE id < \\$1 ORDER BY id DESC LIMIT \\$2").
+			WithArgs("3", 3).
+			WillReturnRows(rows)
+
+		result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
+			Limit:  2,
+			Cursor: &db.Cursor[string]{Key: "3", Direction: db.CursorPrev},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1", result.Items[0].ID)
+		assert.Equal(t, "2", result.Items[1].ID)
+		assert.Empty(t, result.PrevCursor)
+		assert.NotEmpty(t, result.NextCursor)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestInsert(t *testing.T) {
 	config.Init()
 	mockDB, mock, _ := sqlmock.New()
@@ -97,24 +231,80 @@ func TestInsert(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func upsertCounts(inserted int, updated int) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(inserted, updated)
+}
+
 func TestInsertBatch(t *testing.T) {
 	config.Init()
-	mockDB, mock, _ := sqlmock.New()
-	defer mockDB.Close()
-
-	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
-
 	albums := []Album{
 		{ID: "1", Title: "Album 1", Artist: "Artist 1", Price: 9.99},
 		{ID: "2", Title: "Album 2", Artist: "Artist 2", Price: 14.99},
+		{ID: "3", Title: "Album 3", Artist: "Artist 3", Price: 19.99},
 	}
 
-	mock.ExpectExec("INSERT INTO albums").
-		WithArgs("1", "Album 1", "Artist 1", 9.99, "2", "Album 2", "Artist 2", 14.99).
-		WillReturnResult(sqlmock.NewResult(2, 2))
+	t.Run("Upserts the albums in a transaction", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
 
-	err := repo.InsertBatch(testUtils.CreateTestContext(), albums)
-	assert.NoError(t, err)
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectBegin()
+		mock.ExpectQuery("WITH upserted AS \\(INSERT INTO albums \\(id, title, artist, price\\) VALUES \\(\\$1, \\$2, \\$3, \\$4\\), \\(\\$5, \\$6, \\$7, \\$8\\), \\(\\$9, \\$10, \\$11, \\$12\\) ON CONFLICT \\(id\\) DO UPDATE .* RETURNING \\(xmax = 0\\) AS inserted\\)").
+			WithArgs("1", "Album 1", "Artist 1", 9.99, "2", "Album 2", "Artist 2", 14.99, "3", "Album 3", "Artist 3", 19.99).
+			WillReturnRows(upsertCounts(2, 1))
+		mock.ExpectCommit()
+
+		result, err := repo.InsertBatch(testUtils.CreateTestContext(), albums)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportResult{Inserted: 2, Updated: 1}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Splits large batches into chunks", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := &albumRepository{dbConn: testUtils.NewDatabase(mockDB), batchSize: 2, copyThreshold: defaultCopyThreshold}
+		mock.ExpectBegin()
+		mock.ExpectQuery("WITH upserted AS").
+			WithArgs("1", "Album 1", "Artist 1", 9.99, "2", "Album 2", "Artist 2", 14.99).
+			WillReturnRows(upsertCounts(2, 0))
+		mock.ExpectQuery("WITH upserted AS").
+			WithArgs("3", "Album 3", "Artist 3", 19.99).
+			WillReturnRows(upsertCounts(0, 1))
+		mock.ExpectCommit()
+
+		result, err := repo.InsertBatch(testUtils.CreateTestContext(), albums)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportResult{Inserted: 2, Updated: 1}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Copies batches past the threshold", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := &albumRepository{dbConn: testUtils.NewDatabase(mockDB), batchSize: defaultBatchSize, copyThreshold: 3}
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE TEMP TABLE albums_import \\(LIKE albums INCLUDING DEFAULTS\\) ON COMMIT DROP").WillReturnResult(sqlmock.NewResult(0, 0))
+		copyIn := mock.ExpectPrepare("COPY \"albums_import\" \\(\"id\", \"title\", \"artist\", \"price\"\\) FROM STDIN")
+		for _, album := range albums {
+			copyIn.ExpectExec().WithArgs(album.ID, album.Title, album.Artist, album.Price).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		copyIn.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectQuery("WITH upserted AS \\(INSERT INTO albums \\(id, title, artist, price\\) SELECT id, title, artist, price FROM albums_import ON CONFLICT").
+			WillReturnRows(upsertCounts(3, 0))
+		mock.ExpectExec("DROP TABLE albums_import").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		result, err := repo.InsertBatch(testUtils.CreateTestContext(), albums)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportResult{Inserted: 3}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestGetAlbumsNoResults(t *testing.T) {
@@ -124,13 +314,12 @@ func TestGetAlbumsNoResults(t *testing.T) {
 
 	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
 
-	mock.ExpectQuery("SELECT \\* FROM albums").WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}))
+	mock.ExpectQuery("SELECT id, title, artist, price FROM albums").WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}))
 	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM albums").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 
 	result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
-		Artist: "",
-		Limit:  10,
-		Page:   0,
+		Limit: 10,
+		Page:  0,
 	})
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -144,12 +333,11 @@ func TestGetAlbumsError(t *testing.T) {
 
 	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
 
-	mock.ExpectQuery("SELECT \\* FROM albums").WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery("SELECT id, title, artist, price FROM albums").WillReturnError(sql.ErrConnDone)
 
 	result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
-		Artist: "",
-		Limit:  10,
-		Page:   0,
+		Limit: 10,
+		Page:  0,
 	})
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -179,8 +367,9 @@ func TestInsertBatchEmptySlice(t *testing.T) {
 
 	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
 
-	err := repo.InsertBatch(testUtils.CreateTestContext(), []Album{})
+	result, err := repo.InsertBatch(testUtils.CreateTestContext(), []Album{})
 	assert.NoError(t, err)
+	assert.Equal(t, &ImportResult{}, result)
 }
 
 func TestInsertBatchError(t *testing.T) {
@@ -195,12 +384,16 @@ func TestInsertBatchError(t *testing.T) {
 		{ID: "2", Title: "Album 2", Artist: "Artist 2", Price: 14.99},
 	}
 
-	mock.ExpectExec("INSERT INTO albums").
+	mock.ExpectBegin()
+	mock.ExpectQuery("WITH upserted AS").
 		WithArgs("1", "Album 1", "Artist 1", 9.99, "2", "Album 2", "Artist 2", 14.99).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	err := repo.InsertBatch(testUtils.CreateTestContext(), albums)
-	assert.Error(t, err)
+	result, err := repo.InsertBatch(testUtils.CreateTestContext(), albums)
+	assert.Nil(t, result)
+	assert.Equal(t, db.ConnectionError, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetAlbumsScanError(t *testing.T) {
@@ -209,16 +402,172 @@ func TestGetAlbumsScanError(t *testing.T) {
 	defer mockDB.Close()
 
 	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
-	mock.ExpectQuery("SELECT \\* FROM albums").
+	mock.ExpectQuery("SELECT id, title, artist, price FROM albums").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}).
 			AddRow("1", "Album 1", "Artist 1", "invalid_price"))
 
 	result, err := repo.GetAlbums(testUtils.CreateTestContext(), GetAlbumsParams{
-		Artist: "",
-		Limit:  10,
-		Page:   0,
+		Limit: 10,
+		Page:  0,
 	})
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Equal(t, db.DatabaseError, err)
 }
+
+func TestGetAlbumRepository(t *testing.T) {
+	config.Init()
+
+	t.Run("Album found", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectPrepare("SELECT id, title, artist, price FROM albums WHERE id = \\$1").
+			ExpectQuery().
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}).AddRow("1", "Album 1", "Artist 1", 9.99))
+
+		result, err := repo.GetAlbum(testUtils.CreateTestContext(), "1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &Album{ID: "1", Title: "Album 1", Artist: "Artist 1", Price: 9.99}, result)
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectPrepare("SELECT id, title, artist, price FROM albums WHERE id = \\$1").
+			ExpectQuery().
+			WithArgs("404").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}))
+
+		result, err := repo.GetAlbum(testUtils.CreateTestContext(), "404")
+
+		assert.Nil(t, result)
+		assert.Equal(t, db.NotFoundError, err)
+	})
+}
+
+func TestInsertDuplicate(t *testing.T) {
+	config.Init()
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+
+	repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+
+	album := Album{ID: "1", Title: "New Album", Artist: "New Artist", Price: 19.99}
+	mock.ExpectExec("INSERT INTO albums").
+		WithArgs(album.ID, album.Title, album.Artist, album.Price).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err := repo.Insert(testUtils.CreateTestContext(), album)
+	assert.Equal(t, db.UniqueViolationError, err)
+}
+
+func TestUpdateRepository(t *testing.T) {
+	config.Init()
+	album := Album{ID: "1", Title: "Updated Album", Artist: "Artist 1", Price: 11.99}
+
+	t.Run("Album updated", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectExec("UPDATE albums SET title = \\$1, artist = \\$2, price = \\$3 WHERE id = \\$4").
+			WithArgs(album.Title, album.Artist, album.Price, album.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Update(testUtils.CreateTestContext(), album)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectExec("UPDATE albums").
+			WithArgs(album.Title, album.Artist, album.Price, album.ID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Update(testUtils.CreateTestContext(), album)
+		assert.Equal(t, db.NotFoundError, err)
+	})
+}
+
+func TestPatchRepository(t *testing.T) {
+	config.Init()
+	price := 12.99
+
+	t.Run("Album patched under its row lock", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, artist, price FROM albums WHERE id = \\$1 FOR UPDATE").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}).AddRow("1", "Album 1", "Artist 1", 9.99))
+		mock.ExpectExec("UPDATE albums SET title = \\$1, artist = \\$2, price = \\$3 WHERE id = \\$4").
+			WithArgs("Album 1", "Artist 1", price, "1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		result, err := repo.Patch(testUtils.CreateTestContext(), "1", PatchAlbumRequest{Price: &price})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &Album{ID: "1", Title: "Album 1", Artist: "Artist 1", Price: price}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, artist, price FROM albums WHERE id = \\$1 FOR UPDATE").
+			WithArgs("404").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "artist", "price"}))
+		mock.ExpectRollback()
+
+		result, err := repo.Patch(testUtils.CreateTestContext(), "404", PatchAlbumRequest{Price: &price})
+
+		assert.Nil(t, result)
+		assert.Equal(t, db.NotFoundError, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteRepository(t *testing.T) {
+	config.Init()
+
+	t.Run("Album deleted", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectExec("DELETE FROM albums WHERE id = \\$1").
+			WithArgs("1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Delete(testUtils.CreateTestContext(), "1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Album not found", func(t *testing.T) {
+		mockDB, mock, _ := sqlmock.New()
+		defer mockDB.Close()
+
+		repo := NewAlbumRepository(testUtils.NewDatabase(mockDB))
+		mock.ExpectExec("DELETE FROM albums WHERE id = \\$1").
+			WithArgs("404").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Delete(testUtils.CreateTestContext(), "404")
+		assert.Equal(t, db.NotFoundError, err)
+	})
+}
@@ -2,21 +2,34 @@ package albums
 
 import (
 	"context"
-	"encoding/json"
 	"example/web-service-gin/app/cache"
 	"example/web-service-gin/app/db"
-	"fmt"
 	"time"
 )
 
 const (
-	albumsCacheKeySuffix   = "_albumsArtistFilter"
+	albumsCacheNamespace   = "albums:list"
+	albumsCacheVersion     = 1
 	albumsCacheTTLMinutes  = 10
+	albumsCacheTTLJitter   = 0.1
 	albumsCacheServiceName = "albumsCache"
 )
 
+var albumsCacheKeys = cache.NewKeyBuilder(albumsCacheNamespace, albumsCacheVersion)
+
+// albumsListTag tags every cached list page. Any album can appear on any page once
+// filters and sorting are involved, so every write purges all of them.
+const albumsListTag = albumsCacheNamespace
+
 type AlbumService interface {
 	GetAlbums(ctx context.Context, params GetAlbumsParams) (*db.Paginated[Album], error)
+	GetAlbum(ctx context.Context, id string) (*Album, error)
+	CreateAlbum(ctx context.Context, album Album) (*Album, error)
+	UpdateAlbum(ctx context.Context, album Album) (*Album, error)
+	PatchAlbum(ctx context.Context, id string, patch PatchAlbumRequest) (*Album, error)
+	DeleteAlbum(ctx context.Context, id string) error
+	// ImportAlbums inserts or updates every album and reports how many of each
+	ImportAlbums(ctx context.Context, albums []Album) (*ImportResult, error)
 }
 
 type albumService struct {
@@ -32,24 +45,88 @@ func NewAlbumService(cacher cache.Cacher, albumsRepository AlbumRepository) Albu
 }
 
 func (as *albumService) GetAlbums(ctx context.Context, params GetAlbumsParams) (*db.Paginated[Album], error) {
+	// Pages are keyed by the generation of the lists, which every write bumps, so a page loaded
+	// before a write commits but stored after its invalidation is never read
+	generation, err := albumsCacheKeys.Generation(as.cacher, albumsCacheServiceName, ctx)
+	if err != nil {
+		return as.albumsRepository.GetAlbums(ctx, params)
+	}
+	albumSearchCacheKey, err := albumsCacheKeys.BuildAt(params.normalized(), generation)
+	if err != nil {
+		return as.albumsRepository.GetAlbums(ctx, params)
+	}
+
+	return cache.GetOrLoad(as.cacher, albumsCacheServiceName, ctx, albumSearchCacheKey, time.Minute*albumsCacheTTLMinutes,
+		func(ctx context.Context) (*db.Paginated[Album], error) {
+			// A page is cached for minutes, and the load right after invalidateLists must see the write
+			// that caused it, which a lagging replica may not have replayed yet. Cache fills are rare,
+			// one per page and TTL, so they read from the primary while uncached reads use the replicas.
+			return as.albumsRepository.GetAlbums(db.WithPrimary(ctx), params)
+		},
+		cache.WithTags(albumsListTag),
+		cache.WithJitter(albumsCacheTTLJitter),
+	)
+}
 
-	albumSearchCacheKey := fmt.Sprintf("%s:%s", albumsCacheKeySuffix, params.Artist)
-	cachedAlbums, err := as.cacher.Get(serviceName, ctx, albumSearchCacheKey)
-	if err == nil && cachedAlbums != "" {
-		var filteredAlbums db.Paginated[Album]
-		marshallError := json.Unmarshal([]byte(cachedAlbums), &filteredAlbums)
+func (as *albumService) GetAlbum(ctx context.Context, id string) (*Album, error) {
+	return as.albumsRepository.GetAlbum(ctx, id)
+}
 
-		// TODO create better error handling
-		return &filteredAlbums, marshallError
+func (as *albumService) CreateAlbum(ctx context.Context, album Album) (*Album, error) {
+	if err := as.albumsRepository.Insert(ctx, album); err != nil {
+		return nil, err
 	}
+	as.invalidateLists(ctx)
+	return &album, nil
+}
 
-	albums, err := as.albumsRepository.GetAlbums(ctx, params)
-	if err == nil {
-		// TODO save request to cache for later fetching
-		marshalledAlbums, marshallError := json.Marshal(albums)
-		if marshallError == nil {
-			as.cacher.Set(albumsCacheServiceName, ctx, albumSearchCacheKey, string(marshalledAlbums), time.Minute*albumsCacheTTLMinutes)
-		}
+func (as *albumService) UpdateAlbum(ctx context.Context, album Album) (*Album, error) {
+	if err := as.albumsRepository.Update(ctx, album); err != nil {
+		return nil, err
 	}
-	return albums, err
+	as.invalidateLists(ctx)
+	return &album, nil
+}
+
+// PatchAlbum applies the patch to the album under its row lock, so concurrent patches don't
+// overwrite each other's fields with the values they read before the other one committed
+func (as *albumService) PatchAlbum(ctx context.Context, id string, patch PatchAlbumRequest) (*Album, error) {
+	album, err := as.albumsRepository.Patch(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	as.invalidateLists(ctx)
+	return album, nil
+}
+
+func (as *albumService) DeleteAlbum(ctx context.Context, id string) error {
+	if err := as.albumsRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	as.invalidateLists(ctx)
+	return nil
+}
+
+func (as *albumService) ImportAlbums(ctx context.Context, albums []Album) (*ImportResult, error) {
+	result, err := as.albumsRepository.InsertBatch(ctx, albums)
+	if err != nil {
+		return nil, err
+	}
+	as.invalidateLists(ctx)
+	return result, nil
+}
+
+// invalidateLists moves the lists to their next generation and purges every cached page after a
+// successful write. The write already happened, so a cache failure is not returned to the client.
+// It is recorded on the span and the client context, and the pages still expire with their TTL.
+//
+// Inside a transaction the pages are invalidated once it commits: invalidated before, they could be
+// refilled with the rows the transaction is replacing and kept until their TTL. A load that read
+// those rows before the commit can still store its page after the invalidation, under the previous
+// generation, which GetAlbums no longer reads.
+func (as *albumService) invalidateLists(ctx context.Context) {
+	db.AfterCommit(ctx, func(ctx context.Context) {
+		albumsCacheKeys.BumpGeneration(as.cacher, albumsCacheServiceName, ctx)
+		as.cacher.InvalidateTags(albumsCacheServiceName, ctx, albumsListTag)
+	})
 }
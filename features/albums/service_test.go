@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"example/web-service-gin/app/cache"
 	"example/web-service-gin/app/db"
+	"example/web-service-gin/testUtils"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -18,19 +21,44 @@ type MockAlbumRepository struct {
 }
 
 func (m *MockAlbumRepository) GetAlbums(ctx context.Context, params GetAlbumsParams) (*db.Paginated[Album], error) {
-	artist := params.Artist
-	args := m.Called(ctx, artist)
+	args := m.Called(ctx, params)
 	return args.Get(0).(*db.Paginated[Album]), args.Error(1)
 }
 
+func (m *MockAlbumRepository) GetAlbum(ctx context.Context, id string) (*Album, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*Album), args.Error(1)
+}
+
+func (m *MockAlbumRepository) Update(ctx context.Context, album Album) error {
+	args := m.Called(ctx, album)
+	return args.Error(0)
+}
+
+func (m *MockAlbumRepository) Patch(ctx context.Context, id string, patch PatchAlbumRequest) (*Album, error) {
+	args := m.Called(ctx, id, patch)
+	result, _ := args.Get(0).(*Album)
+	return result, args.Error(1)
+}
+
+func (m *MockAlbumRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func (m *MockAlbumRepository) Insert(ctx context.Context, album Album) error {
 	args := m.Called(ctx, album)
 	return args.Error(0)
 }
 
-func (m *MockAlbumRepository) InsertBatch(ctx context.Context, albums []Album) error {
+func (m *MockAlbumRepository) InsertBatch(ctx context.Context, albums []Album) (*ImportResult, error) {
 	args := m.Called(ctx, albums)
-	return args.Error(0)
+	result, _ := args.Get(0).(*ImportResult)
+	return result, args.Error(1)
 }
 
 // Mock Cacher
@@ -48,6 +76,72 @@ func (m *MockCacher) Set(serviceName string, ctx context.Context, key string, va
 	return args.Error(0)
 }
 
+func (m *MockCacher) SetNX(serviceName string, ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	args := m.Client.Called(ctx, key, value, expiration)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCacher) Delete(serviceName string, ctx context.Context, keys ...string) error {
+	args := m.Client.Called(ctx, keys)
+	return args.Error(0)
+}
+
+func (m *MockCacher) CompareAndDelete(serviceName string, ctx context.Context, key string, value string) (bool, error) {
+	args := m.Client.Called(ctx, key, value)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCacher) SetWithTags(serviceName string, ctx context.Context, key string, value string, expiration time.Duration, tags ...string) error {
+	args := m.Client.Called(ctx, key, value, expiration, tags)
+	return args.Error(0)
+}
+
+func (m *MockCacher) InvalidateTags(serviceName string, ctx context.Context, tags ...string) error {
+	args := m.Client.Called(ctx, tags)
+	return args.Error(0)
+}
+
+func (m *MockCacher) MGet(serviceName string, ctx context.Context, keys ...string) (map[string]string, error) {
+	args := m.Client.Called(ctx, keys)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (m *MockCacher) MSet(serviceName string, ctx context.Context, values map[string]string, expiration time.Duration) error {
+	args := m.Client.Called(ctx, values, expiration)
+	return args.Error(0)
+}
+
+func (m *MockCacher) TTL(serviceName string, ctx context.Context, key string) (time.Duration, error) {
+	args := m.Client.Called(ctx, key)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockCacher) Expire(serviceName string, ctx context.Context, key string, expiration time.Duration) error {
+	args := m.Client.Called(ctx, key, expiration)
+	return args.Error(0)
+}
+
+func (m *MockCacher) Exists(serviceName string, ctx context.Context, key string) (bool, error) {
+	args := m.Client.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCacher) Incr(serviceName string, ctx context.Context, key string) (int64, error) {
+	args := m.Client.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCacher) Decr(serviceName string, ctx context.Context, key string) (int64, error) {
+	args := m.Client.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// withinJitter matches the list page ttl after it was spread by albumsCacheTTLJitter
+func withinJitter(ttl time.Duration) bool {
+	expected := float64(time.Minute * albumsCacheTTLMinutes)
+	return math.Abs(float64(ttl)-expected) <= expected*albumsCacheTTLJitter
+}
+
 func TestNewAlbumService(t *testing.T) {
 	mockCacher := new(MockCacher)
 	mockRepo := new(MockAlbumRepository)
@@ -63,7 +157,21 @@ func TestGetAlbumsService(t *testing.T) {
 	service := NewAlbumService(mockCacher, mockRepo)
 
 	ctx := context.Background()
-	artist := "Test Artist"
+	// Loads run on a context detached from the request, see cache.GetOrLoad
+	loadCtx := mock.Anything
+	// Cache fills read from the primary, so a page never caches data a replica hasn't replayed yet
+	primaryCtx := mock.MatchedBy(db.ReadsFromPrimary)
+	params := GetAlbumsParams{
+		Artists: []string{"Test Artist"},
+		Limit:   10,
+		Page:    1,
+	}
+	// Pages are keyed by the generation of the lists, bumped by every write
+	generationKey := albumsCacheKeys.GenerationKey()
+	cacheKey, _ := albumsCacheKeys.BuildAt(params.normalized(), 3)
+	expectGeneration := func() {
+		mockCacher.Client.On("Get", ctx, generationKey).Return("3", nil).Once()
+	}
 
 	t.Run("Cache hit", func(t *testing.T) {
 		expectedAlbums := &db.Paginated[Album]{
@@ -71,13 +179,10 @@ func TestGetAlbumsService(t *testing.T) {
 		}
 		cachedData, _ := json.Marshal(expectedAlbums)
 
-		mockCacher.Client.On("Get", ctx, "_albumsArtistFilter:Test Artist").Return(string(cachedData), nil).Once()
+		expectGeneration()
+		mockCacher.Client.On("Get", ctx, cacheKey).Return(string(cachedData), nil).Once()
 
-		albums, err := service.GetAlbums(ctx, GetAlbumsParams{
-			Artist: artist,
-			Limit:  10,
-			Page:   0,
-		})
+		albums, err := service.GetAlbums(ctx, params)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedAlbums, albums)
@@ -89,14 +194,44 @@ func TestGetAlbumsService(t *testing.T) {
 			Items: []Album{{ID: "2", Title: "Another Album", Artist: "Test Artist", Price: 14.99}},
 		}
 
-		mockCacher.Client.On("Get", ctx, "_albumsArtistFilter:Test Artist").Return("", cache.ErrCacheMiss).Once()
-		mockRepo.On("GetAlbums", ctx, artist).Return(expectedAlbums, nil).Once()
+		expectGeneration()
+		mockCacher.Client.On("Get", ctx, cacheKey).Return("", cache.ErrCacheMiss).Once()
+		mockRepo.On("GetAlbums", primaryCtx, params).Return(expectedAlbums, nil).Once()
+		mockCacher.Client.On("SetWithTags", loadCtx, cacheKey, mock.Anything, mock.MatchedBy(withinJitter), []string{albumsListTag}).Return(nil).Once()
 
-		albums, err := service.GetAlbums(ctx, GetAlbumsParams{
-			Artist: artist,
-			Limit:  10,
-			Page:   0,
-		})
+		albums, err := service.GetAlbums(ctx, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedAlbums, albums)
+		mockCacher.Client.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Repository errors are not cached", func(t *testing.T) {
+		expectGeneration()
+		mockCacher.Client.On("Get", ctx, cacheKey).Return("", cache.ErrCacheMiss).Once()
+		mockRepo.On("GetAlbums", primaryCtx, params).Return((*db.Paginated[Album])(nil), db.NotFoundError).Once()
+
+		albums, err := service.GetAlbums(ctx, params)
+
+		assert.Equal(t, db.NotFoundError, err)
+		assert.Nil(t, albums)
+		mockCacher.Client.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Corrupted cache entry", func(t *testing.T) {
+		expectedAlbums := &db.Paginated[Album]{
+			Items: []Album{{ID: "3", Title: "Third Album", Artist: "Test Artist", Price: 4.99}},
+		}
+
+		expectGeneration()
+		mockCacher.Client.On("Get", ctx, cacheKey).Return("{corrupted", nil).Once()
+		mockCacher.Client.On("Delete", ctx, []string{cacheKey}).Return(nil).Once()
+		mockRepo.On("GetAlbums", primaryCtx, params).Return(expectedAlbums, nil).Once()
+		mockCacher.Client.On("SetWithTags", loadCtx, cacheKey, mock.Anything, mock.MatchedBy(withinJitter), []string{albumsListTag}).Return(nil).Once()
+
+		albums, err := service.GetAlbums(ctx, params)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expectedAlbums, albums)
@@ -104,3 +239,219 @@ func TestGetAlbumsService(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestGetAlbumsServiceInvalidation(t *testing.T) {
+	ctx := context.Background()
+	params := GetAlbumsParams{Limit: 10, Page: 1}
+
+	t.Run("A page loaded before a write isn't served after it", func(t *testing.T) {
+		cacher := cache.NewMemoryCacher(100, 0, testUtils.NewAppTracer())
+		mockRepo := new(MockAlbumRepository)
+		service := NewAlbumService(cacher, mockRepo)
+		before := &db.Paginated[Album]{Items: []Album{{ID: "1", Title: "Blue Train"}}}
+		after := &db.Paginated[Album]{Items: []Album{}}
+
+		loading := make(chan struct{})
+		release := make(chan struct{})
+		mockRepo.On("GetAlbums", mock.Anything, params).Run(func(mock.Arguments) {
+			close(loading)
+			<-release
+		}).Return(before, nil).Once()
+		mockRepo.On("Delete", ctx, "1").Return(nil).Once()
+		mockRepo.On("GetAlbums", mock.Anything, params).Return(after, nil).Once()
+
+		// The load reads the page, then the delete commits and invalidates before the load stores it
+		loaded := make(chan *db.Paginated[Album])
+		go func() {
+			albums, _ := service.GetAlbums(ctx, params)
+			loaded <- albums
+		}()
+		<-loading
+		assert.NoError(t, service.DeleteAlbum(ctx, "1"))
+		close(release)
+		assert.Equal(t, before, <-loaded)
+
+		albums, err := service.GetAlbums(ctx, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, after, albums, "The page stored after the invalidation should not be read")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Pages aren't cached while the cache is unavailable", func(t *testing.T) {
+		mockCacher := new(MockCacher)
+		mockRepo := new(MockAlbumRepository)
+		service := NewAlbumService(mockCacher, mockRepo)
+		expectedAlbums := &db.Paginated[Album]{Items: []Album{{ID: "1", Title: "Blue Train"}}}
+		mockCacher.Client.On("Get", ctx, albumsCacheKeys.GenerationKey()).Return("", cache.ErrCacheGeneric).Once()
+		mockRepo.On("GetAlbums", ctx, params).Return(expectedAlbums, nil).Once()
+
+		albums, err := service.GetAlbums(ctx, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedAlbums, albums)
+		mockCacher.Client.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestAlbumsCacheKey(t *testing.T) {
+	minPrice := 10.5
+	params := GetAlbumsParams{
+		Artists:  []string{"Miles Davis", "John Coltrane"},
+		Title:    "blue",
+		MinPrice: &minPrice,
+		Sort:     []SortField{{Field: "price", Descending: true}, {Field: "title"}},
+		Limit:    20,
+		Page:     2,
+	}
+	key, err := albumsCacheKeys.Build(params.normalized())
+	assert.NoError(t, err)
+
+	reordered := params
+	reordered.Artists = []string{"John Coltrane", "Miles Davis"}
+	reorderedKey, _ := albumsCacheKeys.Build(reordered.normalized())
+	assert.Equal(t, key, reorderedKey, "Artist order should not change the key")
+	assert.Equal(t, []string{"Miles Davis", "John Coltrane"}, params.Artists, "Normalizing should not modify the params")
+
+	nextPage := params
+	nextPage.Page = 3
+	nextPageKey, _ := albumsCacheKeys.Build(nextPage.normalized())
+	assert.NotEqual(t, key, nextPageKey, "Pages should not share a key")
+
+	largerPage := params
+	largerPage.Limit = 50
+	largerPageKey, _ := albumsCacheKeys.Build(largerPage.normalized())
+	assert.NotEqual(t, key, largerPageKey, "Page sizes should not share a key")
+}
+
+func TestAlbumServiceWrites(t *testing.T) {
+	ctx := context.Background()
+	album := Album{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 56.99}
+
+	newInvalidatingCacher := func() *MockCacher {
+		mockCacher := new(MockCacher)
+		mockCacher.Client.On("Incr", ctx, albumsCacheKeys.GenerationKey()).Return(int64(1), nil).Once()
+		mockCacher.Client.On("InvalidateTags", ctx, []string{albumsListTag}).Return(nil).Once()
+		return mockCacher
+	}
+
+	t.Run("Create album", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := newInvalidatingCacher()
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("Insert", ctx, album).Return(nil).Once()
+
+		created, err := service.CreateAlbum(ctx, album)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &album, created)
+		mockRepo.AssertExpectations(t)
+		mockCacher.Client.AssertExpectations(t)
+	})
+
+	t.Run("Create duplicate album does not invalidate", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := new(MockCacher)
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("Insert", ctx, album).Return(db.UniqueViolationError).Once()
+
+		created, err := service.CreateAlbum(ctx, album)
+
+		assert.Equal(t, db.UniqueViolationError, err)
+		assert.Nil(t, created)
+		mockCacher.Client.AssertNotCalled(t, "InvalidateTags", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Patch album only changes present fields", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := newInvalidatingCacher()
+		service := NewAlbumService(mockCacher, mockRepo)
+		title := "Blue Train (Remastered)"
+		expected := Album{ID: "1", Title: title, Artist: "John Coltrane", Price: 56.99}
+		mockRepo.On("Patch", ctx, "1", PatchAlbumRequest{Title: &title}).Return(&expected, nil).Once()
+
+		patched, err := service.PatchAlbum(ctx, "1", PatchAlbumRequest{Title: &title})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &expected, patched)
+		mockRepo.AssertExpectations(t)
+		mockCacher.Client.AssertExpectations(t)
+	})
+
+	t.Run("Patch missing album", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := new(MockCacher)
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("Patch", ctx, "404", PatchAlbumRequest{}).Return(nil, db.NotFoundError).Once()
+
+		patched, err := service.PatchAlbum(ctx, "404", PatchAlbumRequest{})
+
+		assert.Equal(t, db.NotFoundError, err)
+		assert.Nil(t, patched)
+		mockCacher.Client.AssertNotCalled(t, "InvalidateTags", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Delete album", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := newInvalidatingCacher()
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("Delete", ctx, "1").Return(nil).Once()
+
+		err := service.DeleteAlbum(ctx, "1")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+		mockCacher.Client.AssertExpectations(t)
+	})
+
+	t.Run("Import albums", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := newInvalidatingCacher()
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("InsertBatch", ctx, []Album{album}).Return(&ImportResult{Inserted: 1}, nil).Once()
+
+		result, err := service.ImportAlbums(ctx, []Album{album})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &ImportResult{Inserted: 1}, result)
+		mockRepo.AssertExpectations(t)
+		mockCacher.Client.AssertExpectations(t)
+	})
+
+	t.Run("Imports in a transaction invalidate once it commits", func(t *testing.T) {
+		mockDB, sqlMock, _ := sqlmock.New()
+		defer mockDB.Close()
+		database := testUtils.NewDatabase(mockDB)
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectCommit()
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := newInvalidatingCacher()
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("InsertBatch", mock.Anything, []Album{album}).Return(&ImportResult{Inserted: 1}, nil).Once()
+
+		err := database.WithTx(ctx, nil, func(ctx context.Context) error {
+			_, err := service.ImportAlbums(ctx, []Album{album})
+			mockCacher.Client.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+			mockCacher.Client.AssertNotCalled(t, "InvalidateTags", mock.Anything, mock.Anything)
+			return err
+		})
+
+		assert.NoError(t, err)
+		mockCacher.Client.AssertExpectations(t)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalidation failure does not fail the write", func(t *testing.T) {
+		mockRepo := new(MockAlbumRepository)
+		mockCacher := new(MockCacher)
+		service := NewAlbumService(mockCacher, mockRepo)
+		mockRepo.On("Delete", ctx, "1").Return(nil).Once()
+		mockCacher.Client.On("Incr", ctx, albumsCacheKeys.GenerationKey()).Return(int64(0), cache.ErrCacheGeneric).Once()
+		mockCacher.Client.On("InvalidateTags", ctx, []string{albumsListTag}).Return(cache.ErrCacheGeneric).Once()
+
+		err := service.DeleteAlbum(ctx, "1")
+
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"example/web-service-gin/app/cache"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// StatusOK is reported while every dependency answers
+	StatusOK = "ok"
+	// StatusDegraded is reported while the cache breaker is open. Requests are still served,
+	// reading the database instead of the cache.
+	StatusDegraded = "degraded"
+)
+
+// Health is the body of GET /health
+type Health struct {
+	Status string `json:"status"`
+	// Cache is the breaker in front of the cache, absent when the cache has none
+	Cache *cache.BreakerStatus `json:"cache,omitempty"`
+}
+
+type HealthController interface {
+	GetHealth(c *gin.Context)
+}
+
+type healthController struct {
+	cacheStatus cache.StatusReporter
+}
+
+// NewHealthController creates the controller of GET /health. cacheStatus may be nil when the cache has no breaker.
+func NewHealthController(cacheStatus cache.StatusReporter) HealthController {
+	return &healthController{cacheStatus}
+}
+
+// GetHealth answers 200 as long as requests can be served, an open cache breaker only degrades them
+func (hc *healthController) GetHealth(c *gin.Context) {
+	health := Health{Status: StatusOK}
+	if hc.cacheStatus != nil {
+		status := hc.cacheStatus.Status()
+		health.Cache = &status
+		if status.State == cache.BreakerOpen {
+			health.Status = StatusDegraded
+		}
+	}
+	c.IndentedJSON(http.StatusOK, health)
+}
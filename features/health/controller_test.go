@@ -0,0 +1,62 @@
+package health
+
+import (
+	"encoding/json"
+	"example/web-service-gin/app/cache"
+	"example/web-service-gin/app/dependencies"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticStatus cache.BreakerStatus
+
+func (s staticStatus) Status() cache.BreakerStatus {
+	return cache.BreakerStatus(s)
+}
+
+// serveHealth registers the routes of the feature and requests GET /health
+func serveHealth(cacheStatus cache.StatusReporter) (*httptest.ResponseRecorder, Health) {
+	router := gin.New()
+	Init(&dependencies.Dependencies{Router: router, CacheStatus: cacheStatus})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+	router.ServeHTTP(w, req)
+
+	var health Health
+	json.Unmarshal(w.Body.Bytes(), &health)
+	return w, health
+}
+
+func TestGetHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Reports the closed breaker of the cache", func(t *testing.T) {
+		w, health := serveHealth(staticStatus{State: cache.BreakerClosed})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, StatusOK, health.Status)
+		assert.Equal(t, &cache.BreakerStatus{State: cache.BreakerClosed}, health.Cache)
+	})
+
+	t.Run("An open breaker degrades the service", func(t *testing.T) {
+		w, health := serveHealth(staticStatus{State: cache.BreakerOpen, Failures: 5, LastError: "connection refused"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, StatusDegraded, health.Status)
+		assert.Equal(t, cache.BreakerOpen, health.Cache.State)
+		assert.Equal(t, "connection refused", health.Cache.LastError)
+	})
+
+	t.Run("A cache without a breaker is left out", func(t *testing.T) {
+		w, health := serveHealth(nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, StatusOK, health.Status)
+		assert.Nil(t, health.Cache)
+	})
+}
@@ -0,0 +1,9 @@
+package health
+
+import "example/web-service-gin/app/dependencies"
+
+func Init(deps *dependencies.Dependencies) {
+	healthController := NewHealthController(deps.CacheStatus)
+
+	deps.Router.GET("/health", healthController.GetHealth)
+}
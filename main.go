@@ -2,7 +2,10 @@ package main
 
 import (
 	"example/web-service-gin/app"
+	"example/web-service-gin/app/dependencies"
 	"example/web-service-gin/features/albums"
+	"example/web-service-gin/features/health"
+	"example/web-service-gin/migrations"
 	"example/web-service-gin/seed"
 	"flag"
 	"os"
@@ -11,7 +14,11 @@ import (
 func RunApp() {
 
 	app.RunServer(app.ServerParams{
-		Routes: albums.Init,
+		Routes: func(deps *dependencies.Dependencies) {
+			health.Init(deps)
+			albums.Init(deps)
+		},
+		RequireMigrations: true,
 	})
 
 }
@@ -24,6 +31,9 @@ func main() {
 		case "seed":
 			seed.Init()
 			os.Exit(0)
+		case "migrate":
+			migrations.Init(args[1:])
+			os.Exit(0)
 		default:
 			RunApp()
 		}
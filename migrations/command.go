@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"context"
+	"example/web-service-gin/app/appTracer"
+	"example/web-service-gin/app/clientContext"
+	"example/web-service-gin/app/db"
+	"example/web-service-gin/config"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/uptrace/uptrace-go/uptrace"
+)
+
+// Dir is where create writes new migrations, relative to the project root
+const Dir = "migrations/sql"
+
+const usage = "usage: migrate up|down|status|create <name>"
+
+// Init runs the migrate command: up, down, status or create <name>
+func Init(args []string) {
+	if len(args) == 0 {
+		panic(fmt.Errorf(usage))
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			panic(fmt.Errorf(usage))
+		}
+		up, down, err := Create(Dir, args[1])
+		if err != nil {
+			panic(fmt.Errorf("failed to create migration: %w", err))
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return
+	}
+
+	config.Init()
+	configFile := config.GetConfig()
+
+	// Migrations are traced like requests, so slow or failing ones show up in uptrace
+	tracer := appTracer.NewAppTracer(configFile)
+	defer uptrace.Shutdown(context.Background())
+
+	dbConn, err := db.NewDatabase(configFile.DB, tracer)
+	if err != nil {
+		panic(fmt.Errorf("failed to connect to database: %w", err))
+	}
+	defer dbConn.Close()
+
+	migrator, err := NewMigrator(dbConn)
+	if err != nil {
+		panic(fmt.Errorf("failed to load migrations: %w", err))
+	}
+
+	// Migrations run outside of any request, so they get a ClientContext of their own to record their calls
+	ctx := context.WithValue(context.Background(), clientContext.ClientContextKey, &clientContext.ClientContext{})
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			panic(fmt.Errorf("failed to apply migrations: %w", err))
+		}
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			panic(fmt.Errorf("failed to revert migration: %w", err))
+		}
+		if reverted == nil {
+			fmt.Println("no applied migrations")
+		} else {
+			fmt.Printf("reverted %04d_%s\n", reverted.Version, reverted.Name)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			panic(fmt.Errorf("failed to read migrations: %w", err))
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		writer.Flush()
+	default:
+		panic(fmt.Errorf(usage))
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Package migrations versions the database schema with the SQL files embedded from the sql directory.
+
+Each migration is a pair of files named <version>_<name>.up.sql and <version>_<name>.down.sql.
+Applied versions are recorded in the schema_migrations table. Up and Down hold a postgres advisory
+lock for the length of their transaction, so replicas starting together apply each migration once.
+Status and Pending only read, so checking the schema at startup neither waits for that lock nor
+needs the rights to create tables.
+*/
+package migrations
+
+import (
+	"context"
+	"embed"
+	"example/web-service-gin/app/db"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	serviceName = "migrations"
+	// advisoryLockKey identifies the migrations lock among the advisory locks of the database
+	advisoryLockKey = 7_326_218_504
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and whether it is applied to the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	dbConn     db.Database
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for the migrations embedded in the binary
+func NewMigrator(dbConn db.Database) (*Migrator, error) {
+	sqlFiles, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(dbConn, sqlFiles)
+}
+
+func newMigrator(dbConn db.Database, sqlFiles fs.FS) (*Migrator, error) {
+	migrations, err := load(sqlFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{dbConn: dbConn, migrations: migrations}, nil
+}
+
+// load reads the migrations ordered by version. Every version needs both an up and a down file.
+func load(sqlFiles fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(sqlFiles, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q, expected <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(sqlFiles, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in one transaction and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		applied = nil
+		appliedAt, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := appliedAt[migration.Version]; ok {
+				continue
+			}
+			if _, err := m.dbConn.ExecContext(serviceName, ctx, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := m.dbConn.ExecContext(serviceName, ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest applied migration and returns it, or nil when none is applied
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		reverted = nil
+		appliedAt, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := appliedAt[migration.Version]; !ok {
+				continue
+			}
+			if _, err := m.dbConn.ExecContext(serviceName, ctx, migration.Down); err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := m.dbConn.ExecContext(serviceName, ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+				return err
+			}
+			reverted = &migration
+			return nil
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every migration and whether it is applied. It reads the primary, which a migration
+// that was just applied has reached before any replica, outside of any transaction or lock.
+// Before the first Up creates schema_migrations, no migration is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	ctx = db.WithPrimary(ctx)
+	var exists bool
+	if err := m.dbConn.QueryRowContext(serviceName, ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	appliedAt := map[int]time.Time{}
+	if exists {
+		var err error
+		if appliedAt, err = m.applied(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations not applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn in a transaction holding the migrations advisory lock.
+// The lock is released with the transaction, even when the process dies half way.
+func (m *Migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.dbConn.WithTx(ctx, nil, func(ctx context.Context) error {
+		if _, err := m.dbConn.ExecContext(serviceName, ctx, "SELECT pg_advisory_xact_lock($1)", advisoryLockKey); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+		if _, err := m.dbConn.ExecContext(serviceName, ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		return fn(ctx)
+	})
+}
+
+// applied returns when each applied version was applied
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := m.dbConn.QueryContext(serviceName, ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	return appliedAt, rows.Err()
+}
+
+// Create writes empty up and down files for a new migration numbered after the last one in dir
+// and returns their paths
+func Create(dir string, name string) (string, string, error) {
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q, use lowercase letters, digits and underscores", name)
+	}
+
+	migrations, err := load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	version := 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%04d_%s", version, name)
+	up := path.Join(dir, base+".up.sql")
+	down := path.Join(dir, base+".down.sql")
+	if err := writeFile(up, fmt.Sprintf("-- %s: write the schema change here\n", base)); err != nil {
+		return "", "", err
+	}
+	if err := writeFile(down, fmt.Sprintf("-- %s: revert the change of %s.up.sql here\n", base, base)); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// writeFile creates the file, refusing to overwrite an existing migration
+func writeFile(name string, content string) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(content)
+	return err
+}
@@ -0,0 +1,178 @@
+package migrations
+
+import (
+	"example/web-service-gin/testUtils"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFiles = fstest.MapFS{
+	"0001_create_albums.up.sql":   {Data: []byte("CREATE TABLE albums (id TEXT)")},
+	"0001_create_albums.down.sql": {Data: []byte("DROP TABLE albums")},
+	"0002_add_tags.up.sql":        {Data: []byte("ALTER TABLE albums ADD COLUMN tags TEXT")},
+	"0002_add_tags.down.sql":      {Data: []byte("ALTER TABLE albums DROP COLUMN tags")},
+}
+
+func setupTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+
+	migrator, err := newMigrator(testUtils.NewDatabase(mockDB), testFiles)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrator, mock
+}
+
+// expectLocked expects the statements every run starts with in its transaction
+func expectLocked(mock sqlmock.Sqlmock, appliedVersions ...int) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(\\$1\\)").WithArgs(advisoryLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, version := range appliedVersions {
+		rows.AddRow(version, time.Date(2024, 1, version, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrator.migrations)
+	assert.Equal(t, 1, migrator.migrations[0].Version)
+	assert.Contains(t, migrator.migrations[0].Up, "id     TEXT PRIMARY KEY")
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Orders migrations by version", func(t *testing.T) {
+		migrations, err := load(testFiles)
+		assert.NoError(t, err)
+		assert.Equal(t, []Migration{
+			{Version: 1, Name: "create_albums", Up: "CREATE TABLE albums (id TEXT)", Down: "DROP TABLE albums"},
+			{Version: 2, Name: "add_tags", Up: "ALTER TABLE albums ADD COLUMN tags TEXT", Down: "ALTER TABLE albums DROP COLUMN tags"},
+		}, migrations)
+	})
+
+	t.Run("Requires a down file", func(t *testing.T) {
+		_, err := load(fstest.MapFS{"0001_create.up.sql": {Data: []byte("SELECT 1")}})
+		assert.EqualError(t, err, "migration 1_create needs both an up and a down file")
+	})
+
+	t.Run("Rejects unexpected files", func(t *testing.T) {
+		_, err := load(fstest.MapFS{"create.sql": {Data: []byte("SELECT 1")}})
+		assert.Error(t, err)
+	})
+}
+
+func TestUp(t *testing.T) {
+	migrator, mock := setupTestMigrator(t)
+	expectLocked(mock, 1)
+	mock.ExpectExec("ALTER TABLE albums ADD COLUMN tags TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(2, "add_tags").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := migrator.Up(testUtils.CreateTestContext())
+
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpRollsBackFailedMigration(t *testing.T) {
+	migrator, mock := setupTestMigrator(t)
+	expectLocked(mock)
+	mock.ExpectExec("CREATE TABLE albums").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(1, "create_albums").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("ALTER TABLE albums ADD COLUMN tags TEXT").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	_, err := migrator.Up(testUtils.CreateTestContext())
+
+	assert.ErrorContains(t, err, "migration 2_add_tags failed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	migrator, mock := setupTestMigrator(t)
+	expectLocked(mock, 1, 2)
+	mock.ExpectExec("ALTER TABLE albums DROP COLUMN tags").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\$1").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, err := migrator.Down(testUtils.CreateTestContext())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reverted.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectStatus expects the reads of Status, outside of any transaction
+func expectStatus(mock sqlmock.Sqlmock, tableExists bool, appliedVersions ...int) {
+	mock.ExpectQuery("SELECT to_regclass\\('schema_migrations'\\) IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tableExists))
+	if !tableExists {
+		return
+	}
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, version := range appliedVersions {
+		rows.AddRow(version, time.Date(2024, 1, version, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func TestStatusAndPending(t *testing.T) {
+	t.Run("Reads the applied versions without locking", func(t *testing.T) {
+		migrator, mock := setupTestMigrator(t)
+		expectStatus(mock, true, 1)
+
+		pending, err := migrator.Pending(testUtils.CreateTestContext())
+
+		assert.NoError(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "add_tags", pending[0].Name)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Every migration is pending before schema_migrations exists", func(t *testing.T) {
+		migrator, mock := setupTestMigrator(t)
+		expectStatus(mock, false)
+
+		statuses, err := migrator.Status(testUtils.CreateTestContext())
+
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 2)
+		for _, status := range statuses {
+			assert.False(t, status.Applied)
+			assert.Nil(t, status.AppliedAt)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	for name, file := range testFiles {
+		os.WriteFile(filepath.Join(dir, name), file.Data, 0o644)
+	}
+
+	up, down, err := Create(dir, "add_labels")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0003_add_labels.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0003_add_labels.down.sql"), down)
+	assert.FileExists(t, up)
+	assert.FileExists(t, down)
+
+	_, _, err = Create(dir, "Add Labels")
+	assert.Error(t, err)
+}
@@ -0,0 +1,12 @@
+-- Databases seeded before migrations existed already have the table with a SERIAL id,
+-- so the table is only created when missing and the id is converted to the TEXT ids the API uses.
+CREATE TABLE IF NOT EXISTS albums (
+    id     TEXT PRIMARY KEY,
+    title  TEXT NOT NULL,
+    artist TEXT NOT NULL,
+    price  NUMERIC(10, 2)
+);
+
+ALTER TABLE albums ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE albums ALTER COLUMN id TYPE TEXT;
+DROP SEQUENCE IF EXISTS albums_id_seq;
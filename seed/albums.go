@@ -2,9 +2,12 @@ package seed
 
 import (
 	"context"
-	"database/sql"
+	"example/web-service-gin/app/cache"
+	"example/web-service-gin/app/clientContext"
 	"example/web-service-gin/app/db"
+	"example/web-service-gin/app/logger"
 	"example/web-service-gin/features/albums"
+	"example/web-service-gin/migrations"
 	"fmt"
 )
 
@@ -31,34 +34,42 @@ var data = []albums.Album{
 	{ID: "20", Title: "Dookie", Artist: "Green Day", Price: 18.99},
 }
 
-func createAlbumsTable(ctx context.Context, db *sql.DB) error {
-	_, err := db.ExecContext(ctx, `
-		CREATE TABLE IF NOT EXISTS albums (
-			id     SERIAL PRIMARY KEY,
-			title  TEXT NOT NULL,
-			artist TEXT NOT NULL,
-			price  NUMERIC(10, 2)
-		)
-	`)
-	return err
-}
+func SeedAlbums(dbConn db.Database, cacher cache.Cacher) error {
+	// Seeding runs outside of any request, so it gets a ClientContext of its own to record its calls
+	ctx := context.WithValue(context.Background(), clientContext.ClientContextKey, &clientContext.ClientContext{})
 
-func SeedAlbums(dbConn db.Database) error {
-	// Create the albums table if it doesn't exist
-	if err := createAlbumsTable(context.Background(), dbConn.GetClient()); err != nil {
-		return fmt.Errorf("fatal error cannot create Album Table: %w", err)
+	// Bring the schema up to date, creating the albums table on a new database
+	migrator, err := migrations.NewMigrator(dbConn)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
 	}
 
 	albumsRepository := albums.NewAlbumRepository(dbConn)
+	albumService := albums.NewAlbumService(cacher, albumsRepository)
 
-	_, err := dbConn.GetClient().ExecContext(context.Background(), "TRUNCATE TABLE albums")
-	if err != nil {
-		return fmt.Errorf("failed to truncate table: %w", err)
-	}
+	// The truncate and the import are committed together so a failed import keeps the previous albums
+	var inserted int
+	err = dbConn.WithTx(ctx, nil, func(ctx context.Context) error {
+		if _, err := dbConn.ExecContext("seed", ctx, "TRUNCATE TABLE albums"); err != nil {
+			return fmt.Errorf("failed to truncate table: %w", err)
+		}
 
-	err = albumsRepository.InsertBatch(context.Background(), data)
+		// Importing through the service purges the cached album pages once the transaction commits
+		result, err := albumService.ImportAlbums(ctx, data)
+		if err != nil {
+			return fmt.Errorf("failed to insert album: %w", err)
+		}
+		inserted = result.Inserted
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to insert album: %w", err)
+		return err
 	}
+
+	// Logged once committed, as the transaction may be run more than once
+	logger.FromContext(ctx).WithField("inserted", inserted).Info("seeded albums")
 	return nil
 }
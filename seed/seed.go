@@ -1,26 +1,39 @@
 package seed
 
 import (
+	"context"
+	"example/web-service-gin/app/appTracer"
+	"example/web-service-gin/app/cache"
 	"example/web-service-gin/app/db"
+	"example/web-service-gin/app/logger"
 	"example/web-service-gin/config"
-	"example/web-service-gin/testUtils"
 	"fmt"
+
+	"github.com/uptrace/uptrace-go/uptrace"
 )
 
 func Init() {
 	config.Init()
 	configFile := config.GetConfig()
 
+	logger.New(configFile.Log, configFile.AppName)
+	tracer := appTracer.NewAppTracer(configFile)
+	defer uptrace.Shutdown(context.Background())
+
 	// Initialize database connection
-	// TODO: Review using testing app tracer. Not needed for seeding. But may be needed for the future
-	dbConn, err := db.NewDatabase(configFile.DB, testUtils.NewAppTracer())
+	dbConn, err := db.NewDatabase(configFile.DB, tracer)
 	if err != nil {
 		// Handle error
 		panic(fmt.Errorf("failed to connect to database: %w", err))
 	}
 
-	// Create the albums table if it doesn't exist
-	if err := SeedAlbums(dbConn); err != nil {
-		panic(fmt.Errorf("fatal error cannot create Album Table: %w", err))
+	cacher, err := cache.NewCacherFromConfig(configFile, tracer)
+	if err != nil {
+		panic(fmt.Errorf("failed to create cache: %w", err))
+	}
+
+	// Migrate the schema and replace the albums with the seed data
+	if err := SeedAlbums(dbConn, cacher); err != nil {
+		panic(fmt.Errorf("fatal error cannot seed albums: %w", err))
 	}
 }
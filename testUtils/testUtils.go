@@ -30,8 +30,9 @@ func CreateTestContext() context.Context {
 type dummyAppTracer struct {
 }
 
+// CreateSpan returns the span already in the context, which is a no-op span in tests
 func (d *dummyAppTracer) CreateSpan(ctx context.Context, serviceName string) (context.Context, trace.Span) {
-	return ctx, nil
+	return ctx, trace.SpanFromContext(ctx)
 }
 
 func NewAppTracer() appTracer.AppTracer {